@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// archiveRefPrefix is where archived branches are kept
+const archiveRefPrefix = "refs/archive/"
+
+// archiveConfigSection holds what an archived branch loses with its branch.<name>.*
+// config: gitsyncArchive.<name>.description, .date (unix) and .remote (if pushed)
+const archiveConfigSection = "gitsyncArchive."
+
+// ArchivedBranch is a branch moved to refs/archive/<name>
+type ArchivedBranch struct {
+	Name        string
+	SHA         string
+	Subject     string // subject of the branch's last commit
+	Description string
+	Date        time.Time // when it was archived
+	Remote      string    // remote the archive ref was pushed to, if any
+}
+
+// ArchiveBranch moves a branch to refs/archive/<name>, keeping its description.
+// With remote set, the archive ref is pushed to the branch's push remote and the
+// branch is deleted there, so the branch is moved on the remote too. The branch
+// is backed up first, so archiving can be undone like a delete.
+func ArchiveBranch(branchName string, remote bool, config *Config, backup *Backup) error {
+	// Resolve everything first: deleting the branch also drops its git config
+	pushRemote := config.PushRemote(branchName)
+	description := GetBranchTag(branchName)
+	archiveRef := archiveRefPrefix + branchName
+
+	sha, err := gitOutput("rev-parse", "--verify", "refs/heads/"+branchName)
+	if err != nil {
+		return fmt.Errorf("no branch named '%s'", branchName)
+	}
+	if runGit("rev-parse", "-q", "--verify", archiveRef) == nil {
+		return fmt.Errorf("'%s' is already archived, restore or remove %s first", branchName, archiveRef)
+	}
+	if err := backupRefs(backup, branchName, pushRemote); err != nil {
+		return err
+	}
+
+	if err := gitCombined("update-ref", "-m", "gitsync: archive "+branchName, archiveRef, sha, ""); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", branchName, err)
+	}
+	// The archive ref holds the commits, so the branch can be force-deleted
+	if err := DeleteLocalBranch(branchName, true); err != nil {
+		runGit("update-ref", "-d", archiveRef, sha)
+		return err
+	}
+
+	section := archiveConfigSection + branchName
+	runGit("config", "--remove-section", section)
+	if description != "" {
+		runGit("config", section+".description", description)
+	}
+	runGit("config", section+".date", strconv.FormatInt(time.Now().Unix(), 10))
+
+	if remote {
+		if err := gitCombined("push", pushRemote, archiveRef+":"+archiveRef); err != nil {
+			return fmt.Errorf("archived locally, but pushing %s failed: %w", archiveRef, err)
+		}
+		runGit("config", section+".remote", pushRemote)
+		if err := DeleteRemoteBranch(branchName, pushRemote); err != nil {
+			return fmt.Errorf("archived, but deleting the branch on %s failed: %w", pushRemote, err)
+		}
+	}
+	return nil
+}
+
+// ListArchivedBranches returns the archived branches, most recently archived first
+func ListArchivedBranches() ([]ArchivedBranch, error) {
+	output, err := gitOutput("for-each-ref", "--format=%(refname)\t%(objectname)\t%(committerdate:unix)\t%(subject)", archiveRefPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var archived []ArchivedBranch
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(line, "\t", 4)
+		if len(parts) != 4 {
+			continue
+		}
+		a := ArchivedBranch{Name: strings.TrimPrefix(parts[0], archiveRefPrefix), SHA: parts[1], Subject: parts[3]}
+		section := archiveConfigSection + a.Name
+		a.Description, _ = gitOutput("config", section+".description")
+		a.Remote, _ = gitOutput("config", section+".remote")
+
+		// Archives made elsewhere (e.g. fetched) have no date: fall back to the last commit
+		date, err := gitOutput("config", section+".date")
+		if err != nil {
+			date = parts[2]
+		}
+		if unix, err := strconv.ParseInt(date, 10, 64); err == nil {
+			a.Date = time.Unix(unix, 0)
+		}
+		archived = append(archived, a)
+	}
+	sort.SliceStable(archived, func(i, j int) bool { return archived[i].Date.After(archived[j].Date) })
+	return archived, nil
+}
+
+// RestoreArchivedBranch recreates an archived branch with its description and
+// removes the archive. If the archive was pushed, the branch is pushed back to
+// that remote and the remote archive ref is removed too.
+func RestoreArchivedBranch(a ArchivedBranch) error {
+	if runGit("rev-parse", "-q", "--verify", "refs/heads/"+a.Name) == nil {
+		return fmt.Errorf("a branch named '%s' already exists", a.Name)
+	}
+	if err := gitCombined("branch", a.Name, a.SHA); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", a.Name, err)
+	}
+	if a.Description != "" {
+		SetBranchTag(a.Name, a.Description)
+	}
+
+	archiveRef := archiveRefPrefix + a.Name
+	if a.Remote != "" {
+		if err := gitCombined("push", a.Remote, "refs/heads/"+a.Name+":refs/heads/"+a.Name); err != nil {
+			return fmt.Errorf("restored locally, but pushing to %s failed (the archive is kept): %w", a.Remote, err)
+		}
+		if err := gitCombined("push", a.Remote, "--delete", archiveRef); err != nil {
+			return fmt.Errorf("restored, but removing %s from %s failed (the archive is kept): %w", archiveRef, a.Remote, err)
+		}
+	}
+
+	if err := gitCombined("update-ref", "-d", archiveRef, a.SHA); err != nil {
+		return fmt.Errorf("restored, but removing %s failed: %w", archiveRef, err)
+	}
+	runGit("config", "--remove-section", archiveConfigSection+a.Name)
+	return nil
+}
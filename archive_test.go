@@ -0,0 +1,171 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchiveBranch(t *testing.T) {
+	archive := "update-ref -m gitsync: archive feat refs/archive/feat " + oldSHA + " "
+	tests := []struct {
+		name      string
+		remote    bool
+		archived  bool  // refs/archive/feat already exists
+		deleteErr error // of 'git branch -D'
+		wantErr   bool
+		commands  []string
+		skipped   []string
+	}{
+		{
+			name: "local",
+			commands: []string{
+				"update-ref " + backupRefPrefix + "run/heads/feat " + oldSHA,
+				archive,
+				"branch -D feat",
+				"config gitsyncArchive.feat.description Login page",
+			},
+			skipped: []string{"push fork refs/archive/feat:refs/archive/feat", "push fork --delete feat"},
+		},
+		{
+			name:   "pushed to the push remote",
+			remote: true,
+			commands: []string{
+				archive,
+				"push fork refs/archive/feat:refs/archive/feat",
+				"config gitsyncArchive.feat.remote fork",
+				"push fork --delete feat",
+			},
+		},
+		{
+			name:     "already archived",
+			archived: true,
+			wantErr:  true,
+			skipped:  []string{archive, "branch -D feat"},
+		},
+		{
+			name:      "delete failed",
+			deleteErr: errNoRef,
+			wantErr:   true,
+			commands:  []string{archive, "update-ref -d refs/archive/feat " + oldSHA},
+			skipped:   []string{"config gitsyncArchive.feat.description Login page"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeRunner(t)
+			fake.On("config branch.feat.pushRemote", "fork\n", nil)
+			fake.On("config branch.feat.description", "Login page\n", nil)
+			fake.On("rev-parse --verify refs/heads/feat", oldSHA+"\n", nil)
+			fake.On("rev-parse -q --verify refs/heads/feat^{commit}", oldSHA+"\n", nil)
+			fake.On("rev-parse -q --verify "+backupRefPrefix+"run/heads/feat", "", errNoRef)
+			fake.On("branch -D feat", "error: cannot delete branch 'feat'", tt.deleteErr)
+			if !tt.archived {
+				fake.On("rev-parse -q --verify refs/archive/feat", "", errNoRef)
+			}
+
+			err := ArchiveBranch("feat", tt.remote, &Config{OriginRemote: "origin"}, &Backup{ID: "run"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ArchiveBranch() = %v, want error: %v", err, tt.wantErr)
+			}
+			checkCalls(t, fake, tt.commands, tt.skipped)
+		})
+	}
+}
+
+func TestListArchivedBranches(t *testing.T) {
+	fake := useFakeRunner(t)
+	fake.On("for-each-ref --format=%(refname)\t%(objectname)\t%(committerdate:unix)\t%(subject) refs/archive/", strings.Join([]string{
+		"refs/archive/old\t" + oldSHA + "\t1700000000\tAdd the old thing",
+		"refs/archive/feature/login\t" + newSHA + "\t1700000000\tAdd login",
+		"refs/archive/fetched\t" + newSHA + "\t1750000000\tMade elsewhere",
+	}, "\n"), nil)
+	fake.On("config gitsyncArchive.old.date", "1760000000\n", nil)
+	fake.On("config gitsyncArchive.feature/login.date", "1770000000\n", nil)
+	fake.On("config gitsyncArchive.feature/login.description", "Login page\n", nil)
+	fake.On("config gitsyncArchive.feature/login.remote", "fork\n", nil)
+	fake.On("config gitsyncArchive.fetched.date", "", errNoRef)
+
+	archived, err := ListArchivedBranches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ArchivedBranch{
+		{Name: "feature/login", SHA: newSHA, Subject: "Add login", Description: "Login page", Remote: "fork", Date: time.Unix(1770000000, 0)},
+		{Name: "old", SHA: oldSHA, Subject: "Add the old thing", Date: time.Unix(1760000000, 0)},
+		// Without a date, the last commit's is used
+		{Name: "fetched", SHA: newSHA, Subject: "Made elsewhere", Date: time.Unix(1750000000, 0)},
+	}
+	if !reflect.DeepEqual(archived, want) {
+		t.Errorf("ListArchivedBranches() =\n%+v\nwant\n%+v", archived, want)
+	}
+}
+
+func TestRestoreArchivedBranch(t *testing.T) {
+	removeArchive := "update-ref -d refs/archive/feat " + oldSHA
+	tests := []struct {
+		name     string
+		exists   bool
+		pushErr  error
+		wantErr  bool
+		commands []string
+		skipped  []string
+	}{
+		{
+			name: "restored",
+			commands: []string{
+				"branch feat " + oldSHA,
+				"config branch.feat.description Login page",
+				"push fork refs/heads/feat:refs/heads/feat",
+				"push fork --delete refs/archive/feat",
+				removeArchive,
+				"config --remove-section gitsyncArchive.feat",
+			},
+		},
+		{
+			name:    "branch exists",
+			exists:  true,
+			wantErr: true,
+			skipped: []string{"branch feat " + oldSHA, removeArchive},
+		},
+		{
+			name:     "push failed",
+			pushErr:  errNoRef,
+			wantErr:  true,
+			commands: []string{"branch feat " + oldSHA},
+			skipped:  []string{"push fork --delete refs/archive/feat", removeArchive},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeRunner(t)
+			if !tt.exists {
+				fake.On("rev-parse -q --verify refs/heads/feat", "", errNoRef)
+			}
+			fake.On("push fork refs/heads/feat:refs/heads/feat", "! [rejected]", tt.pushErr)
+
+			a := ArchivedBranch{Name: "feat", SHA: oldSHA, Description: "Login page", Remote: "fork"}
+			err := RestoreArchivedBranch(a)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RestoreArchivedBranch() = %v, want error: %v", err, tt.wantErr)
+			}
+			checkCalls(t, fake, tt.commands, tt.skipped)
+		})
+	}
+}
+
+// checkCalls reports commands that should have run but didn't, and skipped ones that ran
+func checkCalls(t *testing.T, fake *FakeRunner, commands []string, skipped []string) {
+	t.Helper()
+	for _, command := range commands {
+		if !fake.Called(command) {
+			t.Errorf("%q was not run, calls: %q", command, fake.Calls)
+		}
+	}
+	for _, command := range skipped {
+		if fake.Called(command) {
+			t.Errorf("%q was run", command)
+		}
+	}
+}
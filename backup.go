@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupRefPrefix is where the old SHAs of every ref gitsync mutates are recorded
+const backupRefPrefix = "refs/gitsync/backup/"
+
+// backupIDFormat names backup runs; it sorts chronologically
+const backupIDFormat = "20060102T150405.000Z"
+
+// Backup records the SHA every ref had before a run touched it, under
+// refs/gitsync/backup/<id>/heads/<branch> and refs/gitsync/backup/<id>/remotes/<remote>/<branch>.
+// A nil *Backup records nothing.
+type Backup struct {
+	ID       string
+	recorded int
+}
+
+// BackupRef is a single ref saved by a backup
+type BackupRef struct {
+	Remote string // empty for local branches
+	Branch string
+	SHA    string
+}
+
+// BackupRun is a backup taken during a single run
+type BackupRun struct {
+	ID   string
+	Time time.Time
+	Refs []BackupRef
+}
+
+// NewBackup starts a backup for a new run
+func NewBackup() *Backup {
+	return &Backup{ID: time.Now().UTC().Format(backupIDFormat)}
+}
+
+// RecordBranch saves the current SHA of a local branch, unless this run already saved it
+func (b *Backup) RecordBranch(branchName string) error {
+	if b == nil {
+		return nil
+	}
+	return b.record("heads/"+branchName, "refs/heads/"+branchName)
+}
+
+// RecordRemote saves the last known SHA of a branch on a remote (its remote-tracking ref).
+// Force-with-lease pushes only succeed while the remote still matches it.
+func (b *Backup) RecordRemote(remote string, branchName string) error {
+	if b == nil {
+		return nil
+	}
+	return b.record("remotes/"+remote+"/"+branchName, "refs/remotes/"+remote+"/"+branchName)
+}
+
+// record copies ref into the backup under name. Refs that don't exist are skipped.
+func (b *Backup) record(name string, ref string) error {
+	sha, err := gitOutput("rev-parse", "-q", "--verify", ref+"^{commit}")
+	if err != nil || sha == "" {
+		return nil
+	}
+	backupRef := backupRefPrefix + b.ID + "/" + name
+	if runGit("rev-parse", "-q", "--verify", backupRef) == nil {
+		// Keep the SHA from before the run's first change
+		return nil
+	}
+	if err := gitCombined("update-ref", backupRef, sha); err != nil {
+		return fmt.Errorf("failed to back up %s: %w", ref, err)
+	}
+	b.recorded++
+	return nil
+}
+
+// Recorded reports whether the backup saved any refs
+func (b *Backup) Recorded() bool {
+	return b != nil && b.recorded > 0
+}
+
+// ListBackups returns all backup runs, newest first
+func ListBackups() ([]BackupRun, error) {
+	output, err := gitOutput("for-each-ref", "--format=%(refname) %(objectname)", backupRefPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := map[string]*BackupRun{}
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) != 2 {
+			continue
+		}
+		rest := strings.TrimPrefix(parts[0], backupRefPrefix)
+		id, name, ok := strings.Cut(rest, "/")
+		if !ok {
+			continue
+		}
+
+		ref := BackupRef{SHA: parts[1]}
+		if strings.HasPrefix(name, "heads/") {
+			ref.Branch = strings.TrimPrefix(name, "heads/")
+		} else if strings.HasPrefix(name, "remotes/") {
+			remote, branch, _ := strings.Cut(strings.TrimPrefix(name, "remotes/"), "/")
+			ref.Remote = remote
+			ref.Branch = branch
+		} else {
+			continue
+		}
+
+		run, ok := runs[id]
+		if !ok {
+			t, _ := time.Parse(backupIDFormat, id)
+			run = &BackupRun{ID: id, Time: t}
+			runs[id] = run
+		}
+		run.Refs = append(run.Refs, ref)
+	}
+
+	var list []BackupRun
+	for _, run := range runs {
+		list = append(list, *run)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID > list[j].ID })
+	return list, nil
+}
+
+// FindBackup returns the backup run with the given ID, or the latest one if id is empty
+func FindBackup(id string) (*BackupRun, error) {
+	runs, err := ListBackups()
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("no gitsync backups found")
+	}
+	if id == "" {
+		return &runs[0], nil
+	}
+	for i := range runs {
+		if runs[i].ID == id {
+			return &runs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no backup named '%s'", id)
+}
+
+// Describe summarizes the branches in a backup run
+func (r BackupRun) Describe() string {
+	seen := map[string]bool{}
+	var names []string
+	for _, ref := range r.Refs {
+		if !seen[ref.Branch] {
+			seen[ref.Branch] = true
+			names = append(names, ref.Branch)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// RestoreBackup puts every local branch in the run back to its saved SHA and,
+// if remote is set, force-pushes (with lease) the saved SHAs to the remotes.
+// The current state is backed up first, so an undo can itself be undone.
+// It returns one line per restored ref.
+func RestoreBackup(run *BackupRun, remote bool) ([]string, error) {
+	undo := NewBackup()
+	var restored []string
+	var failed []string
+
+	for _, ref := range run.Refs {
+		if ref.Remote != "" {
+			continue
+		}
+		undo.RecordBranch(ref.Branch)
+		if err := restoreLocalBranch(ref.Branch, ref.SHA); err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%v)", ref.Branch, err))
+			continue
+		}
+		restored = append(restored, fmt.Sprintf("%s → %s", ref.Branch, shortSHA(ref.SHA)))
+	}
+
+	if remote {
+		for _, ref := range run.Refs {
+			if ref.Remote == "" {
+				continue
+			}
+			undo.RecordRemote(ref.Remote, ref.Branch)
+			if err := gitCombined("push", "--force-with-lease", ref.Remote, ref.SHA+":refs/heads/"+ref.Branch); err != nil {
+				failed = append(failed, fmt.Sprintf("%s/%s (%v)", ref.Remote, ref.Branch, err))
+				continue
+			}
+			restored = append(restored, fmt.Sprintf("%s/%s → %s", ref.Remote, ref.Branch, shortSHA(ref.SHA)))
+		}
+	}
+
+	if len(failed) > 0 {
+		return restored, fmt.Errorf("failed to restore %s", strings.Join(failed, "; "))
+	}
+	return restored, nil
+}
+
+// restoreLocalBranch moves a branch back to sha, recreating it if it was deleted.
+// A branch checked out in a worktree is reset there, keeping uncommitted changes.
+func restoreLocalBranch(branchName string, sha string) error {
+	if path, ok := worktreeFor(branchName); ok {
+		return gitCombined("-C", path, "reset", "--keep", sha)
+	}
+	return gitCombined("update-ref", "-m", "gitsync: undo", "refs/heads/"+branchName, sha)
+}
+
+// shortSHA abbreviates a SHA for display
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
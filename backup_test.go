@@ -0,0 +1,161 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	oldSHA = "1111111111111111111111111111111111111111"
+	newSHA = "2222222222222222222222222222222222222222"
+)
+
+func TestBackupRecord(t *testing.T) {
+	fake := useFakeRunner(t)
+	backupRef := backupRefPrefix + "run/heads/feat"
+	fake.On("rev-parse -q --verify refs/heads/feat^{commit}", oldSHA+"\n", nil)
+	fake.On("rev-parse -q --verify refs/remotes/origin/feat^{commit}", "", errNoRef)
+	// The backup ref doesn't exist until the first record creates it
+	fake.On("rev-parse -q --verify "+backupRef, "", errNoRef).On("rev-parse -q --verify "+backupRef, oldSHA+"\n", nil)
+
+	var none *Backup
+	if err := none.RecordBranch("feat"); err != nil || none.Recorded() {
+		t.Errorf("nil backup: RecordBranch = %v, Recorded = %v", err, none.Recorded())
+	}
+	if len(fake.Calls) != 0 {
+		t.Errorf("nil backup ran git: %v", fake.Calls)
+	}
+
+	b := &Backup{ID: "run"}
+	if err := b.RecordRemote("origin", "feat"); err != nil || b.Recorded() {
+		t.Errorf("RecordRemote of a missing ref = %v, Recorded = %v, want it skipped", err, b.Recorded())
+	}
+	for i := 0; i < 2; i++ {
+		if err := b.RecordBranch("feat"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	updates := 0
+	for _, call := range fake.Calls {
+		if strings.HasPrefix(call, "update-ref") {
+			updates++
+			if call != "update-ref "+backupRef+" "+oldSHA {
+				t.Errorf("backed up with %q", call)
+			}
+		}
+	}
+	if updates != 1 || !b.Recorded() {
+		t.Errorf("RecordBranch twice made %d backup(s), Recorded = %v, want 1, true", updates, b.Recorded())
+	}
+}
+
+func TestListBackups(t *testing.T) {
+	fake := useFakeRunner(t)
+	fake.On("for-each-ref --format=%(refname) %(objectname) "+backupRefPrefix, strings.Join([]string{
+		backupRefPrefix + "20260102T090000.000Z/heads/feat " + oldSHA,
+		backupRefPrefix + "20260102T090000.000Z/remotes/origin/feat " + oldSHA,
+		backupRefPrefix + "20260103T090000.000Z/heads/main " + newSHA,
+		backupRefPrefix + "20260103T090000.000Z/heads/feature/x " + newSHA,
+		backupRefPrefix + "20260103T090000.000Z/other/ignored " + newSHA,
+	}, "\n"), nil)
+
+	runs, err := ListBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BackupRun{
+		{ID: "20260103T090000.000Z", Time: time.Date(2026, 1, 3, 9, 0, 0, 0, time.UTC), Refs: []BackupRef{
+			{Branch: "main", SHA: newSHA},
+			{Branch: "feature/x", SHA: newSHA},
+		}},
+		{ID: "20260102T090000.000Z", Time: time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC), Refs: []BackupRef{
+			{Branch: "feat", SHA: oldSHA},
+			{Remote: "origin", Branch: "feat", SHA: oldSHA},
+		}},
+	}
+	if !reflect.DeepEqual(runs, want) {
+		t.Errorf("ListBackups() =\n%+v\nwant\n%+v", runs, want)
+	}
+	if got := runs[1].Describe(); got != "feat" {
+		t.Errorf("Describe() = %q, want each branch once", got)
+	}
+
+	latest, err := FindBackup("")
+	if err != nil || latest.ID != "20260103T090000.000Z" {
+		t.Errorf("FindBackup(\"\") = %v, %v, want the latest run", latest, err)
+	}
+	if _, err := FindBackup("nope"); err == nil {
+		t.Error("FindBackup found a run that doesn't exist")
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	run := &BackupRun{ID: "run", Refs: []BackupRef{
+		{Branch: "feat", SHA: oldSHA},
+		{Branch: "main", SHA: oldSHA},
+		{Remote: "origin", Branch: "feat", SHA: oldSHA},
+	}}
+	tests := []struct {
+		name     string
+		remote   bool
+		pushErr  error
+		restored int
+		commands []string
+		skipped  []string
+		wantErr  bool
+	}{
+		{
+			name:     "local only",
+			restored: 2,
+			commands: []string{
+				"update-ref -m gitsync: undo refs/heads/feat " + oldSHA,
+				"-C /repo reset --keep " + oldSHA, // main is checked out
+			},
+			skipped: []string{"push --force-with-lease origin " + oldSHA + ":refs/heads/feat"},
+		},
+		{
+			name:     "with remote",
+			remote:   true,
+			restored: 3,
+			commands: []string{"push --force-with-lease origin " + oldSHA + ":refs/heads/feat"},
+		},
+		{
+			name:     "push rejected",
+			remote:   true,
+			pushErr:  errNoRef,
+			restored: 2,
+			commands: []string{"update-ref -m gitsync: undo refs/heads/feat " + oldSHA},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeRunner(t)
+			fake.On("worktree list --porcelain", "worktree /repo\nHEAD "+newSHA+"\nbranch refs/heads/main\n", nil)
+			fake.On("push --force-with-lease origin "+oldSHA+":refs/heads/feat", "! [rejected] (stale info)", tt.pushErr)
+
+			restored, err := RestoreBackup(run, tt.remote)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RestoreBackup() error = %v, want error: %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "origin/feat") {
+				t.Errorf("RestoreBackup() error = %v, want it to name origin/feat", err)
+			}
+			if len(restored) != tt.restored {
+				t.Errorf("restored = %q, want %d ref(s)", restored, tt.restored)
+			}
+			for _, command := range tt.commands {
+				if !fake.Called(command) {
+					t.Errorf("%q was not run, calls: %q", command, fake.Calls)
+				}
+			}
+			for _, command := range tt.skipped {
+				if fake.Called(command) {
+					t.Errorf("%q was run", command)
+				}
+			}
+		})
+	}
+}
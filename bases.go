@@ -0,0 +1,69 @@
+package main
+
+import "fmt"
+
+// BaseRule sets the base branch of the branches matching a pattern
+type BaseRule struct {
+	Pattern string `yaml:"pattern"`
+	Base    string `yaml:"base"`
+}
+
+// ResolveBase returns the base branch of a branch and where it came from:
+// branch.<name>.gitsyncBase, then the last matching rule in bases, then base_branch.
+// An invalid pattern is ignored, so the branch keeps the default base.
+func (c *Config) ResolveBase(branchName string) (string, string) {
+	if base := GetBranchBase(branchName); base != "" {
+		return base, "branch." + branchName + ".gitsyncBase"
+	}
+
+	var patterns []string
+	for _, rule := range c.Bases {
+		patterns = append(patterns, rule.Pattern)
+	}
+	if i, err := LastMatch(branchName, patterns); err == nil && i >= 0 && c.Bases[i].Base != "" {
+		return c.Bases[i].Base, fmt.Sprintf("bases: %q", c.Bases[i].Pattern)
+	}
+	return c.BaseBranch, "base_branch"
+}
+
+// RunBases returns the base branches a sync of branches has to update: the
+// default base branch first, then every other base in the order they are used.
+// Stacked branches are synced onto their parent and skipped ones not at all,
+// so their bases don't count.
+func RunBases(config *Config, branches []*Branch) []string {
+	bases := []string{config.BaseBranch}
+	seen := map[string]bool{config.BaseBranch: true}
+	for _, b := range branches {
+		if b.Parent != "" || b.Strategy == StrategySkip {
+			continue
+		}
+		if b.Base != "" && !seen[b.Base] {
+			seen[b.Base] = true
+			bases = append(bases, b.Base)
+		}
+	}
+	return bases
+}
+
+// FetchBases fetches the upstream base branch, then every other base a local
+// branch is set to. Only the first one has to succeed: a branch whose own base
+// can't be fetched shows no counts and fails when it is synced.
+func FetchBases(config *Config) error {
+	if err := FetchUpstream(config.UpstreamRemote, config.BaseBranch); err != nil {
+		return err
+	}
+
+	names, err := GetAllBranches()
+	if err != nil {
+		return nil
+	}
+	fetched := map[string]bool{config.BaseBranch: true}
+	for _, name := range names {
+		base, _ := config.ResolveBase(name)
+		if !fetched[base] && base != name {
+			fetched[base] = true
+			FetchUpstream(config.UpstreamRemote, base)
+		}
+	}
+	return nil
+}
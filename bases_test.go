@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestResolveBase(t *testing.T) {
+	fake := useFakeRunner(t)
+	fake.On("config branch.pinned.gitsyncBase", "develop\n", nil)
+
+	config := &Config{BaseBranch: "main", Bases: []BaseRule{
+		{Pattern: "hotfix/*", Base: "release/1.2"},
+		{Pattern: "hotfix/next-*", Base: "release/1.3"},
+		{Pattern: "exp/**", Base: ""},
+	}}
+	tests := []struct {
+		branch string
+		base   string
+		source string
+	}{
+		{"feature/a", "main", "base_branch"},
+		{"hotfix/a", "release/1.2", `bases: "hotfix/*"`},
+		{"hotfix/next-a", "release/1.3", `bases: "hotfix/next-*"`},
+		{"exp/a/b", "main", "base_branch"}, // a rule without a base is ignored
+		{"pinned", "develop", "branch.pinned.gitsyncBase"},
+	}
+	for _, tt := range tests {
+		base, source := config.ResolveBase(tt.branch)
+		if base != tt.base || source != tt.source {
+			t.Errorf("ResolveBase(%q) = %q, %q, want %q, %q", tt.branch, base, source, tt.base, tt.source)
+		}
+	}
+}
+
+func TestResolveBaseInvalidPattern(t *testing.T) {
+	useFakeRunner(t)
+	config := &Config{BaseBranch: "main", Bases: []BaseRule{
+		{Pattern: "hotfix/*", Base: "release/1.2"},
+		{Pattern: "re:(", Base: "broken"},
+	}}
+	if base, source := config.ResolveBase("hotfix/a"); base != "main" || source != "base_branch" {
+		t.Errorf("ResolveBase with an invalid pattern = %q, %q, want the default base", base, source)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cleanup reasons, in the order they are checked: a branch gets the first that applies
+const (
+	CleanupMerged       = "merged"        // the branch is contained in its base branch
+	CleanupSquashMerged = "squash-merged" // its changes landed on the base branch as other commits
+	CleanupGone         = "upstream-gone" // the branch it tracks was deleted on the remote
+	CleanupStale        = "stale"         // no commits in stale_days days
+)
+
+// CleanupReasons lists every cleanup reason
+var CleanupReasons = []string{CleanupMerged, CleanupSquashMerged, CleanupGone, CleanupStale}
+
+// CleanupCandidate is a branch that can probably be deleted, and why
+type CleanupCandidate struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+	Detail string `json:"detail"`
+}
+
+// ForceDelete reports whether the branch can be deleted with 'git branch -D':
+// its changes are on its base branch, so nothing is lost. 'git branch -d'
+// would refuse a squash-merged branch, and a merged one unless it is also
+// merged into HEAD.
+func (c CleanupCandidate) ForceDelete() bool {
+	return c.Reason == CleanupMerged || c.Reason == CleanupSquashMerged
+}
+
+// FindCleanupCandidates classifies the branches that look safe to delete.
+// Branches checked out in a worktree are left out, git won't delete them.
+func FindCleanupCandidates(config *Config, branches []*Branch) ([]CleanupCandidate, error) {
+	output, err := gitOutput("for-each-ref", "--format=%(refname:short)\t%(upstream:short)\t%(upstream:track)\t%(committerdate:unix)", "refs/heads")
+	if err != nil {
+		return nil, err
+	}
+	type refState struct {
+		upstream string
+		gone     bool
+		date     int64
+	}
+	refs := map[string]refState{}
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.Split(line, "\t")
+		if len(parts) != 4 {
+			continue
+		}
+		date, _ := strconv.ParseInt(parts[3], 10, 64)
+		refs[parts[0]] = refState{upstream: parts[1], gone: parts[2] == "[gone]", date: date}
+	}
+
+	worktrees, _ := GetWorktreeBranches()
+	cutoff := time.Now().AddDate(0, 0, -config.StaleDays).Unix()
+
+	var candidates []CleanupCandidate
+	for _, b := range branches {
+		if _, ok := worktrees[b.Name]; ok {
+			continue
+		}
+		ref := "refs/heads/" + b.Name
+		base := config.UpstreamRemote + "/" + b.Base
+		state := refs[b.Name]
+
+		c := CleanupCandidate{Name: b.Name}
+		switch {
+		case runGit("merge-base", "--is-ancestor", ref, base) == nil:
+			c.Reason, c.Detail = CleanupMerged, "merged into "+base
+		case squashMerged(ref, base):
+			c.Reason, c.Detail = CleanupSquashMerged, "its changes are on "+base
+		case state.gone:
+			c.Reason, c.Detail = CleanupGone, state.upstream+" was deleted"
+		case config.StaleDays > 0 && state.date > 0 && state.date < cutoff:
+			days := (time.Now().Unix() - state.date) / (24 * 60 * 60)
+			c.Reason, c.Detail = CleanupStale, fmt.Sprintf("no commits for %d days", days)
+		default:
+			continue
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, nil
+}
+
+// squashMerged reports whether the changes of a branch are already on base as
+// other commits: either the branch's combined diff has the same patch-id as a
+// commit on base (a squash merge), or merging it into base would leave base's
+// tree unchanged (e.g. a rebase merge, or a squash followed by more changes).
+func squashMerged(ref string, base string) bool {
+	mergeBase, err := gitOutput("merge-base", base, ref)
+	if err != nil {
+		return false
+	}
+
+	// A throwaway commit holding the whole branch as one change, compared by patch-id
+	squash, err := gitOutput("commit-tree", ref+"^{tree}", "-p", mergeBase, "-m", "gitsync: squash-merge check")
+	if err == nil {
+		if output, err := gitOutput("cherry", base, squash); err == nil && strings.HasPrefix(output, "-") {
+			return true
+		}
+	}
+
+	output, err := gitOutput("merge-tree", "--write-tree", base, ref)
+	if err != nil {
+		return false
+	}
+	baseTree, err := gitOutput("rev-parse", base+"^{tree}")
+	return err == nil && strings.SplitN(output, "\n", 2)[0] == baseTree
+}
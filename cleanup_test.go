@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindCleanupCandidates(t *testing.T) {
+	old := time.Now().UTC().AddDate(0, 0, -40).Unix()
+	recent := time.Now().UTC().AddDate(0, 0, -2).Unix()
+	names := []string{"merged", "squashed", "rebased", "gone", "stale", "active", "checked-out"}
+
+	fake := useFakeRunner(t)
+	fake.On("for-each-ref --format=%(refname:short)\t%(upstream:short)\t%(upstream:track)\t%(committerdate:unix) refs/heads", strings.Join([]string{
+		fmt.Sprintf("merged\torigin/merged\t\t%d", recent),
+		fmt.Sprintf("squashed\torigin/squashed\t\t%d", recent),
+		fmt.Sprintf("rebased\t\t\t%d", recent),
+		fmt.Sprintf("gone\torigin/gone\t[gone]\t%d", old),
+		fmt.Sprintf("stale\torigin/stale\t[ahead 1]\t%d", old),
+		fmt.Sprintf("active\t\t\t%d", recent),
+		fmt.Sprintf("checked-out\t\t\t%d", old),
+	}, "\n"), nil)
+	fake.On("worktree list --porcelain", "worktree /repo\nHEAD "+oldSHA+"\nbranch refs/heads/checked-out\n", nil)
+	fake.On("rev-parse upstream/main^{tree}", "basetree\n", nil)
+	for _, name := range names {
+		ref := "refs/heads/" + name
+		if name != "merged" {
+			fake.On("merge-base --is-ancestor "+ref+" upstream/main", "", errNoRef)
+		}
+		switch name {
+		case "squashed":
+			fake.On("merge-base upstream/main "+ref, oldSHA+"\n", nil)
+			fake.On("commit-tree "+ref+"^{tree} -p "+oldSHA+" -m gitsync: squash-merge check", newSHA+"\n", nil)
+			fake.On("cherry upstream/main "+newSHA, "- "+newSHA+"\n", nil)
+		case "rebased":
+			fake.On("merge-base upstream/main "+ref, oldSHA+"\n", nil)
+			fake.On("merge-tree --write-tree upstream/main "+ref, "basetree\n", nil)
+		default:
+			fake.On("merge-base upstream/main "+ref, "", errNoRef)
+		}
+	}
+
+	var branches []*Branch
+	for _, name := range names {
+		branches = append(branches, &Branch{Name: name, Base: "main"})
+	}
+	tests := []struct {
+		staleDays int
+		want      []CleanupCandidate
+	}{
+		{30, []CleanupCandidate{
+			{Name: "merged", Reason: CleanupMerged, Detail: "merged into upstream/main"},
+			{Name: "squashed", Reason: CleanupSquashMerged, Detail: "its changes are on upstream/main"},
+			{Name: "rebased", Reason: CleanupSquashMerged, Detail: "its changes are on upstream/main"},
+			{Name: "gone", Reason: CleanupGone, Detail: "origin/gone was deleted"},
+			{Name: "stale", Reason: CleanupStale, Detail: "no commits for 40 days"},
+		}},
+		// stale_days: 0 turns off the stale check
+		{0, []CleanupCandidate{
+			{Name: "merged", Reason: CleanupMerged, Detail: "merged into upstream/main"},
+			{Name: "squashed", Reason: CleanupSquashMerged, Detail: "its changes are on upstream/main"},
+			{Name: "rebased", Reason: CleanupSquashMerged, Detail: "its changes are on upstream/main"},
+			{Name: "gone", Reason: CleanupGone, Detail: "origin/gone was deleted"},
+		}},
+	}
+	for _, tt := range tests {
+		config := &Config{UpstreamRemote: "upstream", StaleDays: tt.staleDays}
+		candidates, err := FindCleanupCandidates(config, branches)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(candidates, tt.want) {
+			t.Errorf("stale_days %d: FindCleanupCandidates =\n%+v\nwant\n%+v", tt.staleDays, candidates, tt.want)
+		}
+	}
+}
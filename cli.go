@@ -0,0 +1,1232 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Exit codes for headless commands
+const (
+	exitOK      = 0 // everything succeeded
+	exitFatal   = 1 // the command could not run (bad usage, config, fetch or base update failed)
+	exitPartial = 2 // the command ran but some branches failed (e.g. rebase conflicts)
+)
+
+// command is a headless subcommand
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(c *command, args []string) int
+}
+
+var commands = []command{
+	{"list", "list [--no-fetch] [--status s1,s2] [--show-hidden] [--json]", "List branches with their status relative to the base branch and their push remote", runList},
+	{"sync", "sync [--all-behind] [--all] [--stash] [--dry-run] [--json] [branch...]", "Rebase (or merge, fast-forward) branches onto the base branch and push them", runSync},
+	{"delete", "delete [--remote] [--force] branch...", "Delete branches locally and, optionally, on their push remote", runDelete},
+	{"cleanup", "cleanup [--no-fetch] [--reason r1,r2] [--stale-days n] [--archive | --delete [--force]] [--remote]", "List merged, squash-merged, upstream-gone and stale branches and, optionally, archive or delete them", runCleanup},
+	{"remote", "remote [--merged] [--no-local] [--json] | remote --delete [--merged] [--no-local] [branch...]", "List the branches on the push remote and, optionally, delete them with a single push", runRemote},
+	{"trash", "trash [--restore branch...]", "List deleted branches, or restore them with their description and remote branch", runTrash},
+	{"archive", "archive [--remote] branch... | archive --list | archive --restore branch...", "Move branches to refs/archive/<name> keeping their description, list or restore them", runArchive},
+	{"tag", "tag branch [description]", "Set a branch description (omit it to remove the tag)", runTag},
+	{"strategy", "strategy branch [rebase|merge|ff-only|skip]", "Set how a branch is synced (omit it to fall back to the config patterns)", runStrategy},
+	{"base", "base branch [base-branch]", "Set the base branch a branch is synced with (omit it to fall back to the config)", runBase},
+	{"checkout", "checkout branch | checkout -b new-branch [--from base]", "Checkout an existing branch or create a new one", runCheckout},
+	{"config", "config show [--origin] | config validate [file...]", "Print the effective configuration (with --origin, the layer each setting came from) or check it for mistakes", runConfig},
+	{"undo", "undo [--list] [--remote] [backup-id]", "Put branches back where they were before a sync or delete run (the latest by default)", runUndo},
+}
+
+// findCommand returns the subcommand with the given name
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// printUsage prints the top-level usage, including subcommands
+func printUsage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage:\n  gitsync [-m]            Launch the interactive TUI\n  gitsync <command> ...   Run a headless command\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+	fmt.Fprintf(out, "\nExit codes: %d success, %d fatal error, %d some branches failed\n", exitOK, exitFatal, exitPartial)
+}
+
+// newFlagSet creates the flag set for a subcommand
+func newFlagSet(c *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gitsync %s\n\n%s\n", c.usage, c.summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses flags that may be interleaved with positional arguments
+// and returns the positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// fatalf prints an error and returns the fatal exit code
+func fatalf(format string, a ...interface{}) int {
+	fmt.Fprintf(os.Stderr, "❌ "+format+"\n", a...)
+	return exitFatal
+}
+
+// loadBranches loads the config and branch info, optionally fetching upstream first
+func loadBranches(fetch bool) (*Config, []*Branch, error) {
+	config, err := LoadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if fetch {
+		if err := FetchBases(config); err != nil {
+			return nil, nil, fmt.Errorf("%w for '%s/%s': %w", ErrFetchFailed, config.UpstreamRemote, config.BaseBranch, err)
+		}
+	}
+
+	filter, err := config.BranchFilter()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	branches, err := GetBranchesWithInfo(config, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, branches, nil
+}
+
+// runList prints all branches with their status
+func runList(c *command, args []string) int {
+	fs := newFlagSet(c)
+	noFetch := fs.Bool("no-fetch", false, "Don't fetch upstream or check the push remotes before reading branch status")
+	status := fs.String("status", "", "Only list branches with one of these statuses: ok, behind, "+strings.Join(OriginStatuses, ", "))
+	showHidden := fs.Bool("show-hidden", false, "Also list branches hidden by include/exclude patterns and the rule that hid them")
+	jsonOut := fs.Bool("json", false, "Print the branch list as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return exitFatal
+	}
+	statuses, err := ParseStatuses(*status)
+	if err != nil {
+		return jsonOrFatal(*jsonOut, err)
+	}
+
+	config, branches, err := loadBranches(!*noFetch)
+	if err != nil {
+		return jsonOrFatal(*jsonOut, err)
+	}
+	if !*noFetch {
+		CheckRemoteHeads(branches)
+	}
+	if len(statuses) > 0 {
+		var matching []*Branch
+		for _, b := range branches {
+			for _, s := range statuses {
+				if b.HasStatus(s) {
+					matching = append(matching, b)
+					break
+				}
+			}
+		}
+		branches = matching
+	}
+
+	var hidden []HiddenBranch
+	if *showHidden {
+		filter, err := config.BranchFilter()
+		if err != nil {
+			return jsonOrFatal(*jsonOut, err)
+		}
+		if hidden, err = GetHiddenBranches(config.BaseBranch, filter); err != nil {
+			return jsonOrFatal(*jsonOut, err)
+		}
+	}
+
+	if *jsonOut {
+		report := ListReport{RepoReport: newRepoReport(config), Branches: []BranchReport{}, Hidden: hidden}
+		for _, b := range branches {
+			report.Branches = append(report.Branches, newBranchReport(b))
+		}
+		printJSON(report)
+		return exitOK
+	}
+
+	fmt.Printf("Base: %s  Remote: %s\n", config.BaseBranch, config.UpstreamRemote)
+	for _, b := range branches {
+		name := b.Name
+		if b.Depth > 0 && len(statuses) == 0 {
+			name = strings.Repeat("  ", b.Depth-1) + "└─ " + b.Name
+		}
+		line := fmt.Sprintf("%-8s %s ↓%d ↑%d", b.Status, name, b.Behind, b.Ahead)
+		if badge := originBadge(b); badge != "" {
+			line += " " + badge
+		}
+		if b.Strategy != StrategyRebase {
+			line += fmt.Sprintf(" [%s]", b.Strategy)
+		}
+		if b.Base != config.BaseBranch {
+			line += fmt.Sprintf(" [on %s]", b.Base)
+		}
+		if b.Description != "" {
+			line += " - " + b.Description
+		}
+		if b.LastCommit != "" {
+			line += fmt.Sprintf(" (%s)", b.LastCommit)
+		}
+		fmt.Println(line)
+	}
+	for _, h := range hidden {
+		fmt.Printf("%-8s %s (%s)\n", "hidden", h.Name, h.Reason)
+	}
+	return exitOK
+}
+
+// runSync brings the given branches up to date with the base branch, each with its strategy, and pushes them
+func runSync(c *command, args []string) int {
+	fs := newFlagSet(c)
+	allBehind := fs.Bool("all-behind", false, "Sync every branch that is behind the base branch")
+	all := fs.Bool("all", false, "Sync every branch")
+	stash := fs.Bool("stash", false, "Stash uncommitted changes when the checked-out branch is synced, and restore them afterwards")
+	dryRun := fs.Bool("dry-run", false, "Show the commits each branch would replay, predicted conflicts and rejected pushes, without changing anything")
+	jsonOut := fs.Bool("json", false, "Print the sync results as JSON")
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return exitFatal
+	}
+	if len(names) == 0 && !*allBehind && !*all {
+		fs.Usage()
+		return exitFatal
+	}
+
+	config, branches, err := loadBranches(true)
+	if err != nil {
+		return jsonOrFatal(*jsonOut, err)
+	}
+
+	targets, err := selectBranches(branches, names, *all, *allBehind)
+	if err != nil {
+		return jsonOrFatal(*jsonOut, err)
+	}
+
+	report := SyncReport{RepoReport: newRepoReport(config), Results: []SyncResult{}}
+	if len(targets) == 0 {
+		if *jsonOut {
+			printJSON(report)
+		} else {
+			fmt.Println("Nothing to sync.")
+		}
+		return exitOK
+	}
+
+	var targetNames []string
+	for _, b := range targets {
+		targetNames = append(targetNames, b.Name)
+	}
+	if *dryRun {
+		return runSyncPlan(config, targets, *jsonOut)
+	}
+	if NeedsStash(targetNames) && !*stash {
+		return jsonOrFatal(*jsonOut, fmt.Errorf("you have uncommitted changes, commit them or rerun with --stash"))
+	}
+
+	restore := CaptureRestorePoint()
+	if NeedsStash(targetNames) {
+		if err := restore.Stash(); err != nil {
+			restore.Restore()
+			return jsonOrFatal(*jsonOut, fmt.Errorf("failed to stash changes: %w", err))
+		}
+	}
+
+	// Stop after the current branch on ctrl+c, so the teardown still runs
+	interrupted, stopWatching := watchInterrupt()
+	defer stopWatching()
+
+	progress := func(format string, a ...interface{}) {
+		if !*jsonOut {
+			fmt.Printf(format, a...)
+		}
+	}
+
+	backup := NewBackup()
+	progress("Updating %s from %s/%s...\n", config.BaseBranch, config.UpstreamRemote, config.BaseBranch)
+	if err := PrepareBase(config, config.BaseBranch, backup); err != nil {
+		return finishRun(restore, jsonOrFatal(*jsonOut, err))
+	}
+
+	// Other base branches only fail the branches synced onto them
+	baseErrs := map[string]error{}
+	for _, base := range RunBases(config, targets)[1:] {
+		progress("Updating %s from %s/%s...\n", base, config.UpstreamRemote, base)
+		baseErrs[base] = PrepareBase(config, base, backup)
+	}
+
+	// Stacked branches are rebased bottom-up and pushed together once their stack is done
+	failed := map[string]bool{}
+	var stacked []*Branch
+	pushStacked := func() {
+		if len(stacked) == 0 {
+			return
+		}
+		var names []string
+		for _, b := range stacked {
+			names = append(names, b.Name)
+		}
+		progress("Pushing %s together... ", strings.Join(names, ", "))
+		err := PushStack(names, config)
+		for _, b := range stacked {
+			report.Results = append(report.Results, newSyncResult(b, err))
+			if err != nil {
+				report.Failed++
+			} else {
+				report.Updated++
+			}
+		}
+		if err != nil {
+			progress("✗ %v\n", err)
+		} else {
+			progress("✓ updated\n")
+		}
+		stacked = nil
+	}
+
+	for i, b := range targets {
+		if interrupted() {
+			progress("Interrupted, %d branch(es) not processed\n", len(targets)-i)
+			break
+		}
+		if b.Strategy != StrategyRebase {
+			progress("[%d/%d] %s (%s)... ", i+1, len(targets), b.Name, b.Strategy)
+		} else {
+			progress("[%d/%d] %s... ", i+1, len(targets), b.Name)
+		}
+
+		var err error
+		switch {
+		case failed[b.Parent]:
+			err = fmt.Errorf("%w: '%s'", ErrParentFailed, b.Parent)
+		case b.Strategy != StrategySkip && b.Parent == "" && baseErrs[b.Base] != nil:
+			err = baseErrs[b.Base]
+		case b.Stack != "":
+			err = RebaseBranch(b, config, backup)
+		default:
+			err = SyncBranch(b, config, backup)
+		}
+
+		switch {
+		case err != nil:
+			failed[b.Name] = true
+			report.Results = append(report.Results, newSyncResult(b, err))
+			report.Failed++
+			progress("✗ %v\n", err)
+		case b.Strategy == StrategySkip:
+			report.Results = append(report.Results, newSyncResult(b, nil))
+			report.Skipped++
+			progress("- skipped (%s)\n", b.StrategySource)
+		case b.Stack != "":
+			stacked = append(stacked, b)
+			progress("✓ %s\n", b.Strategy.Verb())
+		default:
+			report.Results = append(report.Results, newSyncResult(b, nil))
+			report.Updated++
+			progress("✓ %s and pushed\n", b.Strategy.Verb())
+		}
+
+		if EndsStack(targets, i) {
+			pushStacked()
+		}
+	}
+	// Push the last stack: it is still pending if the loop stopped partway through it
+	pushStacked()
+
+	code := exitOK
+	if report.Failed > 0 || report.Updated+report.Skipped+report.Failed < len(targets) {
+		code = exitPartial
+	}
+	code = finishRun(restore, code)
+	if backup.Recorded() {
+		report.Backup = backup.ID
+	}
+
+	if *jsonOut {
+		printJSON(report)
+	} else {
+		fmt.Printf("\n%d updated, %d skipped, %d failed\n", report.Updated, report.Skipped, report.Failed)
+		if backup.Recorded() {
+			fmt.Printf("Undo with: gitsync undo %s\n", backup.ID)
+		}
+	}
+	return code
+}
+
+// runSyncPlan prints what a sync would do. It exits with exitPartial when any step is predicted to fail.
+func runSyncPlan(config *Config, targets []*Branch, jsonOut bool) int {
+	plan, err := PlanSync(config, targets)
+	if err != nil {
+		return jsonOrFatal(jsonOut, err)
+	}
+
+	if jsonOut {
+		printJSON(PlanReport{RepoReport: newRepoReport(config), SyncPlan: plan, Failures: plan.Failures()})
+	} else {
+		printPlan(config, plan)
+	}
+	if plan.Failures() > 0 {
+		return exitPartial
+	}
+	return exitOK
+}
+
+// printPlan prints a sync plan
+func printPlan(config *Config, plan *SyncPlan) {
+	fmt.Println("Dry run: nothing will be changed.")
+
+	for i, base := range plan.AllBases() {
+		switch {
+		case base.From == "":
+			fmt.Printf("%s: no local branch, branches are synced onto %s/%s\n", base.Name, config.UpstreamRemote, base.Name)
+		case base.Diverged && i == 0:
+			fmt.Printf("%s: ✗ has diverged from %s/%s, the sync would stop here\n", base.Name, config.UpstreamRemote, base.Name)
+		case base.Diverged:
+			fmt.Printf("%s: ✗ has diverged from %s/%s, the branches on it would fail\n", base.Name, config.UpstreamRemote, base.Name)
+		case base.Commits == 0:
+			fmt.Printf("%s: up to date, %s\n", base.Name, base.Push.Describe())
+		default:
+			fmt.Printf("%s: fast-forward %s..%s (%d commit(s)), %s\n", base.Name, shortSHA(base.From), shortSHA(base.To), base.Commits, base.Push.Describe())
+		}
+	}
+
+	for i, b := range plan.Branches {
+		fmt.Printf("[%d/%d] %s: ", i+1, len(plan.Branches), b.Name)
+		switch {
+		case b.Strategy == StrategySkip:
+			fmt.Printf("skipped (%s)\n", b.StrategySource)
+			continue
+		case b.Blocked != "":
+			fmt.Printf("✗ %s\n", b.Blocked)
+			continue
+		case b.UpToDate:
+			fmt.Printf("already up to date, %s\n", b.Push.Describe())
+			continue
+		}
+
+		where := "in a temporary worktree"
+		if b.InPlace {
+			where = "in your checkout"
+		}
+		switch b.Strategy {
+		case StrategyMerge:
+			fmt.Printf("merge %s in (%d commit(s)) %s\n", b.Onto, len(b.Commits), where)
+		case StrategyFFOnly:
+			fmt.Printf("fast-forward to %s (%d commit(s)) %s\n", b.Onto, len(b.Commits), where)
+		default:
+			fmt.Printf("replay %d commit(s) onto %s %s\n", len(b.Commits), b.Onto, where)
+		}
+		for _, commit := range b.Commits {
+			fmt.Printf("      %s\n", commit)
+		}
+		switch {
+		case b.Conflict:
+			fmt.Printf("      ✗ conflict predicted in %s\n", strings.Join(b.ConflictFiles, ", "))
+		case b.Unpredictable != "":
+			fmt.Printf("      ? %s\n", b.Unpredictable)
+		default:
+			fmt.Printf("      ✓ no conflicts predicted\n")
+		}
+		fmt.Printf("      %s\n", b.Push.Describe())
+	}
+
+	fmt.Printf("\n%d step(s) predicted to fail\n", plan.Failures())
+}
+
+// finishRun tears down a headless run and returns its exit code. A failed
+// teardown turns an otherwise successful run into a partial failure.
+func finishRun(restore *RestorePoint, code int) int {
+	if err := restore.Restore(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		if code == exitOK {
+			return exitPartial
+		}
+	}
+	return code
+}
+
+// watchInterrupt reports whether ctrl+c (or SIGTERM) was received. Call stop to
+// restore the default signal handling.
+func watchInterrupt() (interrupted func() bool, stop func()) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	return func() bool { return ctx.Err() != nil }, cancel
+}
+
+// selectBranches picks the branches named on the command line, or all (behind) branches,
+// together with the rest of their stacks, in tree order
+func selectBranches(branches []*Branch, names []string, all bool, allBehind bool) ([]*Branch, error) {
+	if all || allBehind {
+		var behind []string
+		for _, b := range branches {
+			if all || b.Behind > 0 {
+				behind = append(behind, b.Name)
+			}
+		}
+		return ExpandStacks(branches, behind), nil
+	}
+
+	for _, name := range names {
+		found := false
+		for _, b := range branches {
+			if b.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown or excluded branch '%s'", name)
+		}
+	}
+
+	// A stack is always synced as a whole, bottom-up
+	return ExpandStacks(branches, names), nil
+}
+
+// runDelete deletes the given branches
+func runDelete(c *command, args []string) int {
+	fs := newFlagSet(c)
+	remote := fs.Bool("remote", false, "Also delete the branches on their push remote (origin_remote by default)")
+	force := fs.Bool("force", false, "Also delete unmerged branches (git branch -D); they are backed up first")
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return exitFatal
+	}
+	if len(names) == 0 {
+		fs.Usage()
+		return exitFatal
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		return fatalf("%v", err)
+	}
+
+	backup := NewBackup()
+	failed := 0
+	var deletingRemote []string
+	if *remote {
+		deletingRemote = remoteCopies(config, names)
+	}
+	for i, name := range names {
+		fmt.Printf("[%d/%d] %s... ", i+1, len(names), name)
+		unmerged, err := checkUnmerged(name, names, deletingRemote, *force)
+		if err == nil {
+			err = DeleteBranch(name, *remote, unmerged != nil, config, backup)
+		}
+		if err != nil {
+			failed++
+			fmt.Printf("✗ %v\n", err)
+			continue
+		}
+		printDeleted(unmerged, backup)
+	}
+
+	fmt.Printf("\n%d deleted, %d failed\n", len(names)-failed, failed)
+	if backup.Recorded() {
+		fmt.Printf("Undo with: gitsync undo %s (or restore single branches with: gitsync trash --restore)\n", backup.ID)
+	}
+	if failed > 0 {
+		return exitPartial
+	}
+	return exitOK
+}
+
+// checkUnmerged returns the branch if 'git branch -d' would refuse it. Without
+// force, that is an error listing the commits a force-delete would lose.
+func checkUnmerged(name string, deleting []string, deletingRemote []string, force bool) (*UnmergedBranch, error) {
+	unmerged, err := CheckUnmerged(name, deleting, deletingRemote)
+	if err != nil || unmerged == nil {
+		// Let 'git branch -d' have the final say
+		return nil, nil
+	}
+	if force {
+		return unmerged, nil
+	}
+	msg := fmt.Sprintf("not merged into %s", unmerged.Into)
+	if len(unmerged.Lost) > 0 {
+		msg += fmt.Sprintf(", -D would lose %d commit(s) no other branch has:", len(unmerged.Lost))
+		for _, commit := range unmerged.Lost {
+			msg += "\n      " + commit
+		}
+		msg += "\n   "
+	} else {
+		msg += " (its commits are on other branches or remotes);"
+	}
+	return nil, fmt.Errorf("%s use --force to delete it anyway", msg)
+}
+
+// printDeleted reports a deleted branch, and where the commits of a force-deleted one are kept
+func printDeleted(unmerged *UnmergedBranch, backup *Backup) {
+	if unmerged == nil {
+		fmt.Println("✓ deleted")
+		return
+	}
+	if len(unmerged.Lost) == 0 {
+		fmt.Println("✓ force-deleted")
+		return
+	}
+	fmt.Printf("✓ force-deleted, its %d unmerged commit(s) are kept in %s%s/heads/%s\n", len(unmerged.Lost), backupRefPrefix, backup.ID, unmerged.Name)
+}
+
+// runCleanup lists the branches that look safe to delete and, with --delete, deletes them
+func runCleanup(c *command, args []string) int {
+	fs := newFlagSet(c)
+	noFetch := fs.Bool("no-fetch", false, "Don't fetch upstream before classifying branches")
+	reason := fs.String("reason", "", "Only list branches with one of these reasons: "+strings.Join(CleanupReasons, ", "))
+	staleDays := fs.Int("stale-days", -1, "Days without commits before a branch is stale, 0 to disable (default: stale_days from the config)")
+	archive := fs.Bool("archive", false, "Archive the listed branches to refs/archive/<name>")
+	del := fs.Bool("delete", false, "Delete the listed branches (merged and squash-merged ones with -D)")
+	force := fs.Bool("force", false, "With --delete, also delete unmerged (e.g. stale) branches with -D; they are backed up first")
+	remote := fs.Bool("remote", false, "With --archive or --delete, also move or delete the branches on their push remote")
+	if _, err := parseArgs(fs, args); err != nil {
+		return exitFatal
+	}
+	if *archive && *del {
+		return fatalf("use either --archive or --delete")
+	}
+
+	var reasons []string
+	for _, r := range strings.Split(*reason, ",") {
+		if r = strings.TrimSpace(r); r == "" {
+			continue
+		}
+		valid := false
+		for _, known := range CleanupReasons {
+			valid = valid || r == known
+		}
+		if !valid {
+			return fatalf("unknown reason %q (use %s)", r, strings.Join(CleanupReasons, ", "))
+		}
+		reasons = append(reasons, r)
+	}
+
+	config, branches, err := loadBranches(!*noFetch)
+	if err != nil {
+		return fatalf("%v", err)
+	}
+	if *staleDays >= 0 {
+		config.Set("stale_days", fmt.Sprint(*staleDays), originFlag+" --stale-days")
+	}
+
+	found, err := FindCleanupCandidates(config, branches)
+	if err != nil {
+		return fatalf("%v", err)
+	}
+	var candidates []CleanupCandidate
+	for _, c := range found {
+		keep := len(reasons) == 0
+		for _, r := range reasons {
+			keep = keep || c.Reason == r
+		}
+		if keep {
+			candidates = append(candidates, c)
+		}
+	}
+
+	if len(candidates) == 0 {
+		fmt.Println("Nothing to clean up")
+		return exitOK
+	}
+	if !*archive && !*del {
+		for _, c := range candidates {
+			fmt.Printf("%-14s %s - %s\n", c.Reason, c.Name, c.Detail)
+		}
+		fmt.Println("\nArchive them with: gitsync cleanup --archive (or delete them with --delete)")
+		return exitOK
+	}
+
+	verb := "deleted"
+	if *archive {
+		verb = "archived"
+	}
+	var deleting []string
+	for _, c := range candidates {
+		deleting = append(deleting, c.Name)
+	}
+	var deletingRemote []string
+	if *remote {
+		deletingRemote = remoteCopies(config, deleting)
+	}
+	backup := NewBackup()
+	failed := 0
+	for i, c := range candidates {
+		fmt.Printf("[%d/%d] %s (%s)... ", i+1, len(candidates), c.Name, c.Reason)
+		var unmerged *UnmergedBranch
+		if *archive {
+			err = ArchiveBranch(c.Name, *remote, config, backup)
+		} else if c.ForceDelete() {
+			err = DeleteBranch(c.Name, *remote, true, config, backup)
+		} else if unmerged, err = checkUnmerged(c.Name, deleting, deletingRemote, *force); err == nil {
+			err = DeleteBranch(c.Name, *remote, unmerged != nil, config, backup)
+		}
+		if err != nil {
+			failed++
+			fmt.Printf("✗ %v\n", err)
+			continue
+		}
+		if *archive {
+			fmt.Println("✓ archived")
+		} else {
+			printDeleted(unmerged, backup)
+		}
+	}
+
+	fmt.Printf("\n%d %s, %d failed\n", len(candidates)-failed, verb, failed)
+	if backup.Recorded() {
+		fmt.Printf("Undo with: gitsync undo %s\n", backup.ID)
+	}
+	if failed > 0 {
+		return exitPartial
+	}
+	return exitOK
+}
+
+// runRemote lists the branches on the default push remote and, with --delete, deletes them
+func runRemote(c *command, args []string) int {
+	fs := newFlagSet(c)
+	merged := fs.Bool("merged", false, "Only branches merged into their base branch, or already gone from the remote")
+	noLocal := fs.Bool("no-local", false, "Only branches without a local branch of the same name")
+	del := fs.Bool("delete", false, "Delete the given branches, or all listed ones, on the remote (leased, they are backed up first)")
+	jsonOut := fs.Bool("json", false, "Print the branch list as JSON")
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return exitFatal
+	}
+	if len(names) > 0 && !*del {
+		fs.Usage()
+		return exitFatal
+	}
+	if *del && *jsonOut {
+		return fatalf("--json only applies to listing")
+	}
+	if *del && len(names) == 0 && !*merged && !*noLocal {
+		return fatalf("name the branches to delete, or narrow them down with --merged or --no-local")
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		return jsonOrFatal(*jsonOut, err)
+	}
+	remote := config.DefaultPushRemote()
+	all, err := ListRemoteBranches(config, remote)
+	if err != nil {
+		return jsonOrFatal(*jsonOut, err)
+	}
+
+	var branches []*RemoteBranch
+	for _, b := range all {
+		if (*merged && !b.Merged && !b.Gone) || (*noLocal && b.Local) {
+			continue
+		}
+		if len(names) > 0 && !slices.Contains(names, b.Name) {
+			continue
+		}
+		branches = append(branches, b)
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(branches, func(b *RemoteBranch) bool { return b.Name == name }) {
+			return fatalf("no branch named '%s' on %s (or it was filtered out)", name, remote)
+		}
+	}
+
+	if !*del {
+		if *jsonOut {
+			printJSON(RemoteReport{RepoReport: newRepoReport(config), Remote: remote, Branches: branches})
+			return exitOK
+		}
+		for _, b := range branches {
+			status := "unmerged"
+			if b.Gone {
+				status = "gone"
+			} else if b.Merged {
+				status = "merged"
+			}
+			local := ""
+			if b.Local {
+				local = "local"
+			}
+			line := fmt.Sprintf("%-8s %-5s %s  %s  %s (%s) %s", status, local, b.Date.Local().Format("2006-01-02"), b.Name, shortSHA(b.SHA), b.Author, b.Subject)
+			if b.Moved {
+				line += " [pushed to since the last fetch]"
+			}
+			fmt.Println(line)
+		}
+		fmt.Printf("\n%d branch(es) on %s\n", len(branches), remote)
+		return exitOK
+	}
+
+	if len(branches) == 0 {
+		fmt.Printf("Nothing to delete on %s\n", remote)
+		return exitOK
+	}
+	backup := NewBackup()
+	deleted, err := DeleteRemoteBranches(remote, branches, backup)
+	for _, name := range deleted {
+		fmt.Printf("✓ deleted %s/%s\n", remote, name)
+	}
+	fmt.Printf("\n%d deleted, %d failed\n", len(deleted), len(branches)-len(deleted))
+	if backup.Recorded() {
+		fmt.Printf("Undo with: gitsync undo --remote %s (or restore single branches with: gitsync trash --restore)\n", backup.ID)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitPartial
+	}
+	return exitOK
+}
+
+// runArchive archives branches, or lists or restores archived ones
+func runArchive(c *command, args []string) int {
+	fs := newFlagSet(c)
+	list := fs.Bool("list", false, "List archived branches instead of archiving")
+	restore := fs.Bool("restore", false, "Restore the given archived branches (and push them back if they were archived on a remote)")
+	remote := fs.Bool("remote", false, "Also push the archive ref to the push remote and delete the branch there")
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return exitFatal
+	}
+
+	if *list {
+		archived, err := ListArchivedBranches()
+		if err != nil {
+			return fatalf("%v", err)
+		}
+		for _, a := range archived {
+			line := fmt.Sprintf("%s  %s  %s", a.Date.Local().Format("2006-01-02"), a.Name, shortSHA(a.SHA))
+			if a.Description != "" {
+				line += " - " + a.Description
+			}
+			if a.Remote != "" {
+				line += fmt.Sprintf(" [on %s]", a.Remote)
+			}
+			fmt.Println(line)
+		}
+		return exitOK
+	}
+
+	if len(names) == 0 {
+		fs.Usage()
+		return exitFatal
+	}
+
+	failed := 0
+	if *restore {
+		archived, err := ListArchivedBranches()
+		if err != nil {
+			return fatalf("%v", err)
+		}
+		for i, name := range names {
+			fmt.Printf("[%d/%d] %s... ", i+1, len(names), name)
+			idx := slices.IndexFunc(archived, func(a ArchivedBranch) bool { return a.Name == name })
+			if idx < 0 {
+				failed++
+				fmt.Printf("✗ no archived branch named '%s'\n", name)
+				continue
+			}
+			if err := RestoreArchivedBranch(archived[idx]); err != nil {
+				failed++
+				fmt.Printf("✗ %v\n", err)
+				continue
+			}
+			fmt.Println("✓ restored")
+		}
+		fmt.Printf("\n%d restored, %d failed\n", len(names)-failed, failed)
+	} else {
+		config, err := LoadConfig()
+		if err != nil {
+			return fatalf("%v", err)
+		}
+		backup := NewBackup()
+		for i, name := range names {
+			fmt.Printf("[%d/%d] %s... ", i+1, len(names), name)
+			if err := ArchiveBranch(name, *remote, config, backup); err != nil {
+				failed++
+				fmt.Printf("✗ %v\n", err)
+				continue
+			}
+			fmt.Printf("✓ archived to %s%s\n", archiveRefPrefix, name)
+		}
+		fmt.Printf("\n%d archived, %d failed\n", len(names)-failed, failed)
+		if backup.Recorded() {
+			fmt.Printf("Undo with: gitsync undo %s (or restore with: gitsync archive --restore)\n", backup.ID)
+		}
+	}
+
+	if failed > 0 {
+		return exitPartial
+	}
+	return exitOK
+}
+
+// runTrash lists the deleted branches in the trash, or restores some of them
+func runTrash(c *command, args []string) int {
+	fs := newFlagSet(c)
+	restore := fs.Bool("restore", false, "Restore the given branches (the most recently deleted one of each name)")
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return exitFatal
+	}
+
+	entries, err := ListTrash()
+	if err != nil {
+		return fatalf("%v", err)
+	}
+
+	if !*restore {
+		if len(names) > 0 {
+			fs.Usage()
+			return exitFatal
+		}
+		for _, e := range entries {
+			line := fmt.Sprintf("%s  %s  %s", e.DeletedAt.Local().Format("2006-01-02 15:04:05"), e.Name, shortSHA(e.SHA))
+			if e.Description != "" {
+				line += " - " + e.Description
+			}
+			if e.RemoteOnly {
+				line += fmt.Sprintf(" [deleted on %s only]", e.Remote)
+			} else if e.RemoteSHA != "" {
+				line += fmt.Sprintf(" [deleted on %s]", e.Remote)
+			}
+			fmt.Println(line)
+		}
+		return exitOK
+	}
+
+	if len(names) == 0 {
+		fs.Usage()
+		return exitFatal
+	}
+	failed := 0
+	for i, name := range names {
+		fmt.Printf("[%d/%d] %s... ", i+1, len(names), name)
+		idx := slices.IndexFunc(entries, func(e TrashEntry) bool { return e.Name == name })
+		if idx < 0 {
+			failed++
+			fmt.Printf("✗ '%s' is not in the trash\n", name)
+			continue
+		}
+		if err := RestoreTrash(entries[idx]); err != nil {
+			failed++
+			fmt.Printf("✗ %v\n", err)
+			continue
+		}
+		if entries[idx].RemoteOnly {
+			fmt.Printf("✓ pushed back to %s at %s\n", entries[idx].Remote, shortSHA(entries[idx].RemoteSHA))
+		} else if entries[idx].RemoteSHA != "" {
+			fmt.Printf("✓ restored at %s and pushed to %s\n", shortSHA(entries[idx].SHA), entries[idx].Remote)
+		} else {
+			fmt.Printf("✓ restored at %s\n", shortSHA(entries[idx].SHA))
+		}
+	}
+
+	fmt.Printf("\n%d restored, %d failed\n", len(names)-failed, failed)
+	if failed > 0 {
+		return exitPartial
+	}
+	return exitOK
+}
+
+// runUndo lists backups, or restores the branches saved in one
+func runUndo(c *command, args []string) int {
+	fs := newFlagSet(c)
+	list := fs.Bool("list", false, "List backups instead of restoring one")
+	remote := fs.Bool("remote", false, "Also force-push (with lease) the saved commits to the remotes")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return exitFatal
+	}
+	if len(positional) > 1 {
+		fs.Usage()
+		return exitFatal
+	}
+
+	if *list {
+		runs, err := ListBackups()
+		if err != nil {
+			return fatalf("%v", err)
+		}
+		for _, run := range runs {
+			fmt.Printf("%s  %s  %s\n", run.ID, run.Time.Local().Format("2006-01-02 15:04:05"), run.Describe())
+		}
+		return exitOK
+	}
+
+	id := ""
+	if len(positional) == 1 {
+		id = positional[0]
+	}
+	run, err := FindBackup(id)
+	if err != nil {
+		return fatalf("%v", err)
+	}
+
+	fmt.Printf("Restoring backup %s...\n", run.ID)
+	restored, err := RestoreBackup(run, *remote)
+	for _, line := range restored {
+		fmt.Printf("✓ %s\n", line)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		return exitPartial
+	}
+	return exitOK
+}
+
+// runTag sets or removes a branch description
+func runTag(c *command, args []string) int {
+	fs := newFlagSet(c)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return exitFatal
+	}
+	if len(positional) == 0 {
+		fs.Usage()
+		return exitFatal
+	}
+
+	branch := positional[0]
+	description := strings.Join(positional[1:], " ")
+	if description == "" {
+		if err := RemoveBranchTag(branch); err != nil {
+			return fatalf("failed to remove tag from %s: %v", branch, err)
+		}
+		fmt.Printf("✓ Removed tag from %s\n", branch)
+		return exitOK
+	}
+
+	if err := SetBranchTag(branch, description); err != nil {
+		return fatalf("failed to tag %s: %v", branch, err)
+	}
+	fmt.Printf("✓ Tagged %s: %s\n", branch, description)
+	return exitOK
+}
+
+// runStrategy sets or removes the sync strategy of a branch
+func runStrategy(c *command, args []string) int {
+	fs := newFlagSet(c)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return exitFatal
+	}
+	if len(positional) == 0 || len(positional) > 2 {
+		fs.Usage()
+		return exitFatal
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		return fatalf("%v", err)
+	}
+
+	branch := positional[0]
+	if len(positional) == 1 {
+		if err := RemoveBranchStrategy(branch); err != nil {
+			return fatalf("no strategy set for %s", branch)
+		}
+	} else {
+		strategy, err := ParseStrategy(positional[1])
+		if err != nil {
+			return fatalf("%v", err)
+		}
+		if err := SetBranchStrategy(branch, strategy); err != nil {
+			return fatalf("failed to set the strategy of %s: %v", branch, err)
+		}
+	}
+
+	strategy, source := config.ResolveStrategy(branch)
+	fmt.Printf("✓ %s is synced with %s (%s)\n", branch, strategy, source)
+	return exitOK
+}
+
+// runBase sets or removes the base branch of a branch
+func runBase(c *command, args []string) int {
+	fs := newFlagSet(c)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return exitFatal
+	}
+	if len(positional) == 0 || len(positional) > 2 {
+		fs.Usage()
+		return exitFatal
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		return fatalf("%v", err)
+	}
+
+	branch := positional[0]
+	if len(positional) == 1 {
+		if err := RemoveBranchBase(branch); err != nil {
+			return fatalf("no base branch set for %s", branch)
+		}
+	} else if err := SetBranchBase(branch, positional[1]); err != nil {
+		return fatalf("failed to set the base branch of %s: %v", branch, err)
+	}
+
+	base, source := config.ResolveBase(branch)
+	fmt.Printf("✓ %s is based on %s (%s)\n", branch, base, source)
+	return exitOK
+}
+
+// runCheckout checks out an existing branch or creates a new one
+func runCheckout(c *command, args []string) int {
+	fs := newFlagSet(c)
+	newBranch := fs.String("b", "", "Create and checkout a new branch with this name")
+	from := fs.String("from", "", "Branch to create the new branch from (default: base branch)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return exitFatal
+	}
+
+	if *newBranch == "" {
+		if len(positional) != 1 {
+			fs.Usage()
+			return exitFatal
+		}
+		if err := CheckoutBranch(positional[0]); err != nil {
+			return fatalf("%v", err)
+		}
+		fmt.Printf("✓ Switched to %s\n", positional[0])
+		return exitOK
+	}
+
+	fromBranch := *from
+	if fromBranch == "" {
+		config, err := LoadConfig()
+		if err != nil {
+			return fatalf("%v", err)
+		}
+		fromBranch = config.BaseBranch
+	}
+	if err := CreateAndCheckoutBranch(*newBranch, fromBranch); err != nil {
+		return fatalf("%v", err)
+	}
+	fmt.Printf("✓ Created %s from %s\n", *newBranch, fromBranch)
+	return exitOK
+}
+
+// runConfig prints the effective configuration
+func runConfig(c *command, args []string) int {
+	fs := newFlagSet(c)
+	origin := fs.Bool("origin", false, "Show the layer each setting came from (default, global, repo, local, env, flag or auto-detected)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return exitFatal
+	}
+	if len(positional) > 0 && positional[0] == "validate" {
+		return validateConfig(positional[1:])
+	}
+	if len(positional) != 1 || positional[0] != "show" {
+		fs.Usage()
+		return exitFatal
+	}
+
+	config, err := LoadConfig()
+	if err != nil {
+		return fatalf("%v", err)
+	}
+	if !*origin {
+		data, err := marshalYAML(config)
+		if err != nil {
+			return fatalf("%v", err)
+		}
+		fmt.Print(data)
+		return exitOK
+	}
+
+	// One setting at a time, with its origin as a comment on its first line
+	for _, key := range ConfigKeys() {
+		data, err := marshalYAML(map[string]interface{}{key: config.field(key).Interface()})
+		if err != nil {
+			return fatalf("%v", err)
+		}
+		first, rest, _ := strings.Cut(data, "\n")
+		fmt.Printf("%-40s # %s\n%s", first, config.Origin(key), rest)
+	}
+	return exitOK
+}
+
+// validateConfig checks the configuration, or each given file in place of the
+// repo's .gitsync.yaml, and prints every problem. It fails if there are any,
+// so it can run in a pre-commit hook.
+func validateConfig(files []string) int {
+	if len(files) == 0 {
+		files = []string{configPath}
+	}
+
+	invalid := 0
+	for _, file := range files {
+		configPath = file
+		name := file
+		if name == "" {
+			name = "configuration"
+		}
+
+		var problems []string
+		config, err := LoadConfig()
+		if err != nil {
+			problems = strings.Split(err.Error(), "\n")
+		} else {
+			for _, err := range config.Validate() {
+				problems = append(problems, err.Error())
+			}
+		}
+
+		if len(problems) == 0 {
+			fmt.Printf("✓ %s is valid\n", name)
+			continue
+		}
+		invalid++
+		for _, problem := range problems {
+			fmt.Printf("❌ %s\n", problem)
+		}
+	}
+	if invalid > 0 {
+		return exitFatal
+	}
+	return exitOK
+}
+
+// marshalYAML encodes v as YAML indented like the example config
+func marshalYAML(v interface{}) (string, error) {
+	var b strings.Builder
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return b.String(), enc.Close()
+}
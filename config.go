@@ -1,57 +1,279 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the configuration file
 type Config struct {
-	BaseBranch      string   `yaml:"base_branch"`
-	UpstreamRemote  string   `yaml:"upstream_remote"`
-	OriginRemote    string   `yaml:"origin_remote"`
-	ExcludePatterns []string `yaml:"exclude_patterns"`
+	BaseBranch      string         `yaml:"base_branch"`
+	UpstreamRemote  string         `yaml:"upstream_remote"`
+	OriginRemote    string         `yaml:"origin_remote"`
+	ExcludePatterns []string       `yaml:"exclude_patterns"`
+	IncludePatterns []string       `yaml:"include_patterns"`
+	PauseOnConflict bool           `yaml:"pause_on_conflict"`
+	Strategies      []StrategyRule `yaml:"strategies"`
+	Bases           []BaseRule     `yaml:"bases"`
+	StaleDays       int            `yaml:"stale_days"`
+	LogFormat       string         `yaml:"log_format"`
+	ManualMode      bool           `yaml:"manual_mode"`
+	FetchOnStart    bool           `yaml:"fetch_on_start"`
+	ShowCommandLog  bool           `yaml:"show_command_log"`
+
+	origins map[string]string // Layer each setting came from, by key
+}
+
+// Configuration layers, in the order they are applied. Each setting is taken
+// from the last layer that sets it; lists replace, rather than extend, the
+// lists of earlier layers.
+const (
+	originDefault  = "default"
+	originGlobal   = "global"        // $XDG_CONFIG_HOME/gitsync/config.yaml
+	originRepo     = "repo"          // .gitsync.yaml at the repository root (or --config), usually checked in
+	originLocal    = "local"         // .git/gitsync.yaml, never checked in
+	originEnv      = "env"           // GITSYNC_<SETTING>, e.g. GITSYNC_BASE_BRANCH
+	originFlag     = "flag"          // command-line flags
+	originDetected = "auto-detected" // base_branch and upstream_remote, if no layer sets them
+)
+
+// configFlags maps the global command-line flags to the settings they override
+var configFlags = map[string]string{
+	"m":                 "manual_mode",
+	"manual":            "manual_mode",
+	"fetch-on-start":    "fetch_on_start",
+	"show-command-log":  "show_command_log",
+	"pause-on-conflict": "pause_on_conflict",
 }
 
-// LoadConfig loads config from .gitsync.yaml or returns defaults
+// LoadConfig loads the config layers on top of the defaults and auto-detects
+// whatever they leave unset
 func LoadConfig() (*Config, error) {
 	config := &Config{
 		BaseBranch:      "",
 		UpstreamRemote:  "",
 		OriginRemote:    "origin",
 		ExcludePatterns: []string{},
+		IncludePatterns: []string{},
+		StaleDays:       90,
+		LogFormat:       DefaultLogFormat,
+		FetchOnStart:    true,
+		ShowCommandLog:  true,
+		origins:         map[string]string{},
+	}
+	for _, key := range ConfigKeys() {
+		config.origins[key] = originDefault
+	}
+
+	if configPath != "" {
+		if _, err := os.Stat(configPath); err != nil {
+			return nil, fmt.Errorf("could not read --config file: %w", err)
+		}
+	}
+	var errs []error
+	for _, layer := range configFiles() {
+		if err := config.loadFile(layer.path, layer.origin); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	for _, key := range ConfigKeys() {
+		name := "GITSYNC_" + strings.ToUpper(key)
+		if value, ok := os.LookupEnv(name); ok {
+			if err := config.Set(key, value, originEnv+" "+name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var flagErr error
+	if flag.Parsed() {
+		flag.Visit(func(f *flag.Flag) {
+			if key, ok := configFlags[f.Name]; ok && flagErr == nil {
+				flagErr = config.Set(key, f.Value.String(), originFlag+" --"+f.Name)
+			}
+		})
+	}
+	if flagErr != nil {
+		return nil, flagErr
 	}
-	
-	// Try to load from file
-	data, err := os.ReadFile(".gitsync.yaml")
-	if err == nil {
-		yaml.Unmarshal(data, config)
+
+	if config.OriginRemote == "" {
+		config.OriginRemote = "origin"
+		config.origins["origin_remote"] = originDefault
 	}
-	
+
 	// Auto-detect if not set
 	if config.BaseBranch == "" {
 		if branch, err := DetectBaseBranch(); err == nil {
 			config.BaseBranch = branch
+			config.origins["base_branch"] = originDetected
 		}
 	}
-	
+
 	if config.UpstreamRemote == "" {
 		if remote, err := DetectUpstreamRemote(); err == nil {
 			config.UpstreamRemote = remote
+			config.origins["upstream_remote"] = originDetected
 		} else if err != nil { // Propagate error from DetectUpstreamRemote
 			return nil, err
 		}
 	}
-	
+
 	return config, nil
 }
 
-// SaveConfig saves config to .gitsync.yaml
-func SaveConfig(config *Config) error {
-	data, err := yaml.Marshal(config)
-	if err != nil {
+// configFile is a config file layer
+type configFile struct {
+	path   string
+	origin string
+}
+
+// configFiles returns the config files to load, in order. Missing ones are skipped.
+func configFiles() []configFile {
+	var files []configFile
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		files = append(files, configFile{filepath.Join(dir, "gitsync", "config.yaml"), originGlobal})
+	} else if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, configFile{filepath.Join(home, ".config", "gitsync", "config.yaml"), originGlobal})
+	}
+	if path, err := RepoConfigPath(); err == nil {
+		files = append(files, configFile{path, originRepo})
+	}
+	if dir, err := gitCommonDir(); err == nil {
+		files = append(files, configFile{filepath.Join(dir, "gitsync.yaml"), originLocal})
+	}
+	return files
+}
+
+// loadFile applies a config file on top of the config, recording the settings
+// it sets. A missing file is skipped; an invalid one is an error.
+func (c *Config) loadFile(path string, origin string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
 		return err
 	}
-	return os.WriteFile(".gitsync.yaml", data, 0644)
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return errors.New(yamlError(path, err.Error()))
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind == yaml.ScalarNode && doc.Content[0].Tag == "!!null" {
+		return nil // empty, or only comments
+	}
+	mapping := doc.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		found := "a single value"
+		if mapping.Kind == yaml.SequenceNode {
+			found = "a list"
+		}
+		return fmt.Errorf("%s:%d: expected settings (key: value), found %s", path, mapping.Line, found)
+	}
+	if err := c.decodeFile(mapping, path); err != nil {
+		return err
+	}
+
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		c.origins[mapping.Content[i].Value] = fmt.Sprintf("%s (%s)", origin, path)
+	}
+	return nil
+}
+
+// Set overrides a setting with a value from the environment or a flag.
+// Strings are taken as is, anything else is parsed as YAML; lists of
+// strings can also be given comma-separated. An empty value resets the setting.
+func (c *Config) Set(key string, value string, origin string) error {
+	field := c.field(key)
+	if !field.IsValid() {
+		return fmt.Errorf("unknown setting '%s' (from %s)", key, origin)
+	}
+
+	switch {
+	case value == "":
+		field.Set(reflect.Zero(field.Type()))
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	default:
+		if field.Type() == reflect.TypeOf([]string{}) && !strings.HasPrefix(strings.TrimSpace(value), "[") {
+			value = "[" + value + "]"
+		}
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(value), &node); err != nil || len(node.Content) == 0 {
+			return fmt.Errorf("invalid %s from %s: %q is not valid YAML", key, origin, value)
+		}
+		if err := node.Content[0].Decode(field.Addr().Interface()); err != nil {
+			return fmt.Errorf("invalid %s from %s: %w", key, origin, err)
+		}
+	}
+	c.origins[key] = origin
+	return nil
+}
+
+// Origin returns the layer a setting came from, e.g. "repo (.gitsync.yaml)"
+func (c *Config) Origin(key string) string {
+	if origin, ok := c.origins[key]; ok {
+		return origin
+	}
+	return originDefault
+}
+
+// field returns the struct field of a setting, or an invalid Value if there is none
+func (c *Config) field(key string) reflect.Value {
+	f, ok := yamlField(reflect.TypeOf(*c), key)
+	if !ok {
+		return reflect.Value{}
+	}
+	return reflect.ValueOf(c).Elem().FieldByIndex(f.Index)
+}
+
+// ConfigKeys lists every setting, in the order of the Config struct
+func ConfigKeys() []string {
+	return yamlKeys(reflect.TypeOf(Config{}))
+}
+
+// PushRemote returns the remote a branch is pushed to. It honors
+// branch.<name>.pushRemote and remote.pushDefault before falling back to origin_remote.
+func (c *Config) PushRemote(branchName string) string {
+	return ResolvePushRemote(branchName, c.OriginRemote)
+}
+
+// BranchFilter compiles the include and exclude patterns
+func (c *Config) BranchFilter() (*BranchFilter, error) {
+	return NewBranchFilter(c.IncludePatterns, c.ExcludePatterns)
+}
+
+// RepoConfigPath returns the path of the repo layer: the file given with
+// --config, or .gitsync.yaml at the root of the current worktree. A linked
+// worktree without its own .gitsync.yaml (e.g. an untracked one) shares the
+// main worktree's. Submodules have their own root, and so their own file.
+// Bare repositories have no repo layer.
+func RepoConfigPath() (string, error) {
+	if configPath != "" {
+		return filepath.Abs(configPath)
+	}
+	root, err := GetWorktreeRoot()
+	if err != nil || root == "" {
+		return "", fmt.Errorf("no worktree to read .gitsync.yaml from")
+	}
+	path := filepath.Join(root, ".gitsync.yaml")
+	if _, err := os.Stat(path); err != nil {
+		if main, err := GetMainWorktreeRoot(); err == nil && !sameDir(main, root) {
+			if _, err := os.Stat(filepath.Join(main, ".gitsync.yaml")); err == nil {
+				return filepath.Join(main, ".gitsync.yaml"), nil
+			}
+		}
+	}
+	return path, nil
 }
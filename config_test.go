@@ -0,0 +1,212 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// emptyConfig returns a config without defaults, for testing single layers
+func emptyConfig() *Config {
+	return &Config{origins: map[string]string{}}
+}
+
+// writeFile writes a test file and returns its path
+func writeFile(t *testing.T, dir string, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigSet(t *testing.T) {
+	tests := []struct {
+		key     string
+		value   string
+		want    interface{}
+		wantErr string
+	}{
+		{"base_branch", "develop", "develop", ""},
+		{"log_format", "--oneline 'x y'", "--oneline 'x y'", ""}, // strings are taken as is
+		{"stale_days", "30", 30, ""},
+		{"stale_days", "", 0, ""}, // empty resets
+		{"stale_days", "soon", nil, "invalid stale_days from env GITSYNC_TEST"},
+		{"pause_on_conflict", "true", true, ""},
+		{"exclude_patterns", "release/*, old/*", []string{"release/*", "old/*"}, ""},
+		{"exclude_patterns", `["a,b"]`, []string{"a,b"}, ""},
+		{"strategies", "[{pattern: shared/**, strategy: merge}]", []StrategyRule{{Pattern: "shared/**", Strategy: StrategyMerge}}, ""},
+		{"strategies", "[oops", nil, "is not valid YAML"},
+		{"base_brnch", "main", nil, "unknown setting 'base_brnch'"},
+	}
+	for _, tt := range tests {
+		c := emptyConfig()
+		c.StaleDays = 90
+		err := c.Set(tt.key, tt.value, "env GITSYNC_TEST")
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Set(%q, %q) error = %v, want %q", tt.key, tt.value, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Set(%q, %q): %v", tt.key, tt.value, err)
+			continue
+		}
+		if got := c.field(tt.key).Interface(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Set(%q, %q) = %#v, want %#v", tt.key, tt.value, got, tt.want)
+		}
+		if origin := c.Origin(tt.key); origin != "env GITSYNC_TEST" {
+			t.Errorf("Origin(%q) = %q", tt.key, origin)
+		}
+	}
+}
+
+func TestLoadFileLayers(t *testing.T) {
+	dir := t.TempDir()
+	global := writeFile(t, dir, "global.yaml", "base_branch: main\nexclude_patterns: [a, b]\nstale_days: 10\n")
+	local := writeFile(t, dir, "local.yaml", "# overrides\nexclude_patterns:\n  - c\n")
+
+	c := emptyConfig()
+	for _, layer := range []configFile{{global, originGlobal}, {filepath.Join(dir, "missing.yaml"), originRepo}, {local, originLocal}} {
+		if err := c.loadFile(layer.path, layer.origin); err != nil {
+			t.Fatalf("loadFile(%s): %v", layer.path, err)
+		}
+	}
+
+	if c.BaseBranch != "main" || c.StaleDays != 10 || !reflect.DeepEqual(c.ExcludePatterns, []string{"c"}) {
+		t.Errorf("config = %q, %d, %v, want main, 10, [c] (lists replace)", c.BaseBranch, c.StaleDays, c.ExcludePatterns)
+	}
+	wantOrigins := map[string]string{
+		"base_branch":      "global (" + global + ")",
+		"stale_days":       "global (" + global + ")",
+		"exclude_patterns": "local (" + local + ")",
+	}
+	if !reflect.DeepEqual(c.origins, wantOrigins) {
+		t.Errorf("origins = %v, want %v", c.origins, wantOrigins)
+	}
+}
+
+func TestLoadFileInvalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"empty.yaml", "", ""},
+		{"comments.yaml", "# nothing yet\n", ""},
+		{"list.yaml", "- main\n", "list.yaml:1: expected settings (key: value), found a list"},
+		{"syntax.yaml", "base_branch: main\nexclude_patterns: [\n", "syntax.yaml:2: did not find expected node content"},
+		{"typo.yaml", "base_branch: main\nbase_brnch: dev\n", "typo.yaml:2: unknown setting 'base_brnch', did you mean 'base_branch'?"},
+		{"type.yaml", "stale_days: soon\n", "type.yaml:1: cannot unmarshal !!str `soon` into int"},
+	}
+	for _, tt := range tests {
+		path := writeFile(t, dir, tt.name, tt.content)
+		err := emptyConfig().loadFile(path, originRepo)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("loadFile(%s): %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || err.Error() != filepath.Join(dir, tt.wantErr)):
+			t.Errorf("loadFile(%s) error = %v, want %s", tt.name, err, filepath.Join(dir, tt.wantErr))
+		}
+	}
+}
+
+func TestLoadConfigLayers(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+	writeFile(t, dir, "xdg/gitsync/config.yaml", "stale_days: 30\npause_on_conflict: true\nmanual_mode: true\nbase_branch: develop\n")
+	repo := writeFile(t, dir, "repo/.gitsync.yaml", "base_branch: main\nupstream_remote: upstream\nexclude_patterns: [old/*]\n")
+	local := writeFile(t, dir, "repo/.git/gitsync.yaml", "origin_remote: fork\n")
+	t.Setenv("GITSYNC_STALE_DAYS", "7")
+
+	fake := useFakeRunner(t)
+	fake.On("rev-parse --path-format=absolute --git-common-dir", filepath.Join(dir, "repo/.git")+"\n", nil)
+	prev := configPath
+	configPath = repo
+	t.Cleanup(func() { configPath = prev })
+
+	config, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key    string
+		value  interface{}
+		origin string
+	}{
+		{"base_branch", "main", "repo (" + repo + ")"},
+		{"upstream_remote", "upstream", "repo (" + repo + ")"},
+		{"origin_remote", "fork", "local (" + local + ")"},
+		{"exclude_patterns", []string{"old/*"}, "repo (" + repo + ")"},
+		{"stale_days", 7, "env GITSYNC_STALE_DAYS"},
+		{"pause_on_conflict", true, "global (" + filepath.Join(dir, "xdg/gitsync/config.yaml") + ")"},
+		{"manual_mode", true, "global (" + filepath.Join(dir, "xdg/gitsync/config.yaml") + ")"},
+		{"fetch_on_start", true, originDefault},
+		{"log_format", DefaultLogFormat, originDefault},
+	}
+	for _, tt := range tests {
+		if got := config.field(tt.key).Interface(); !reflect.DeepEqual(got, tt.value) {
+			t.Errorf("%s = %#v, want %#v", tt.key, got, tt.value)
+		}
+		if origin := config.Origin(tt.key); origin != tt.origin {
+			t.Errorf("Origin(%s) = %q, want %q", tt.key, origin, tt.origin)
+		}
+	}
+}
+
+func TestLoadConfigMissingConfigFlag(t *testing.T) {
+	useFakeRunner(t)
+	prev := configPath
+	configPath = filepath.Join(t.TempDir(), "missing.yaml")
+	t.Cleanup(func() { configPath = prev })
+
+	if _, err := LoadConfig(); err == nil {
+		t.Error("LoadConfig accepted a missing --config file")
+	}
+}
+
+func TestRepoConfigPath(t *testing.T) {
+	dir := t.TempDir()
+	mainFile := writeFile(t, dir, "main/.gitsync.yaml", "base_branch: main\n")
+	linked := filepath.Join(dir, "linked")
+	if err := os.MkdirAll(linked, 0755); err != nil {
+		t.Fatal(err)
+	}
+	worktrees := "worktree " + filepath.Join(dir, "main") + "\nHEAD " + oldSHA + "\nbranch refs/heads/main\n\nworktree " + linked + "\nHEAD " + newSHA + "\nbranch refs/heads/feat\n"
+
+	tests := []struct {
+		name    string
+		flag    string
+		root    string
+		want    string
+		wantErr bool
+	}{
+		{name: "--config", flag: filepath.Join(dir, "other.yaml"), root: filepath.Join(dir, "main"), want: filepath.Join(dir, "other.yaml")},
+		{name: "worktree root", root: filepath.Join(dir, "main"), want: mainFile},
+		{name: "linked worktree without one", root: linked, want: mainFile},
+		{name: "bare repository", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeRunner(t)
+			fake.On("rev-parse --show-toplevel", tt.root+"\n", nil)
+			fake.On("worktree list --porcelain", worktrees, nil)
+			prev := configPath
+			configPath = tt.flag
+			t.Cleanup(func() { configPath = prev })
+
+			path, err := RepoConfigPath()
+			if (err != nil) != tt.wantErr || path != tt.want {
+				t.Errorf("RepoConfigPath() = %q, %v, want %q", path, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import "fmt"
+
+// detailsLimit caps the commits listed per section of the details panel
+const detailsLimit = 50
+
+// detailsFormat is the one-line commit format of the details panel
+const detailsFormat = "--format=%C(yellow)%h%C(reset) %s %C(green)(%cr)%C(reset)"
+
+// BranchDetails is what the details panel shows for a branch
+type BranchDetails struct {
+	Unique   []string // the branch's own commits (base..branch), colored, newest first
+	Incoming []string // commits on the base branch the branch doesn't have yet (branch..base)
+	Stat     []string // diffstat of the branch's changes since it forked off the base branch
+	Error    string   // set if the details could not be loaded
+}
+
+// LoadBranchDetails reads the commits and diffstat of a branch against
+// its base branch on the upstream remote
+func LoadBranchDetails(config *Config, branch *Branch) *BranchDetails {
+	base := config.UpstreamRemote + "/" + branch.Base
+	ref := "refs/heads/" + branch.Name
+	limit := fmt.Sprintf("--max-count=%d", detailsLimit)
+
+	d := &BranchDetails{}
+	var err error
+	if d.Unique, err = gitLines("log", "--color=always", detailsFormat, limit, base+".."+ref, "--"); err != nil {
+		d.Error = fmt.Sprintf("could not compare %s with %s: %v", branch.Name, base, err)
+		return d
+	}
+	if d.Incoming, err = gitLines("log", "--color=always", detailsFormat, limit, ref+".."+base, "--"); err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	if d.Stat, err = gitLines("diff", "--color=always", "--stat=80", base+"..."+ref, "--"); err != nil {
+		d.Error = err.Error()
+	}
+	return d
+}
+
+// BranchDiff returns the colored diff of a branch's changes since it forked off its base branch
+func BranchDiff(config *Config, branch *Branch) ([]string, error) {
+	lines, err := gitLines("diff", "--color=always", "--stat", "--patch", config.UpstreamRemote+"/"+branch.Base+"...refs/heads/"+branch.Name, "--")
+	if err != nil {
+		return nil, fmt.Errorf("git diff failed: %w", err)
+	}
+	return lines, nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Pattern is a compiled branch filter rule. Rules are globs matched against the
+// full branch name ('*' stays within a path segment, '**' crosses segments),
+// or regular expressions when prefixed with "re:". A leading '!' negates the rule.
+type Pattern struct {
+	Source string // the rule as written in the config
+	Negate bool
+	re     *regexp.Regexp
+}
+
+// CompilePattern compiles a single filter rule
+func CompilePattern(rule string) (*Pattern, error) {
+	p := &Pattern{Source: rule}
+	if strings.HasPrefix(rule, "!") {
+		p.Negate = true
+		rule = rule[1:]
+	}
+
+	var expr string
+	if strings.HasPrefix(rule, "re:") {
+		expr = strings.TrimPrefix(rule, "re:")
+	} else {
+		expr = globToRegexp(rule)
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", p.Source, err)
+	}
+	p.re = re
+	return p, nil
+}
+
+// Match reports whether the branch name matches the rule, ignoring negation
+func (p *Pattern) Match(name string) bool {
+	return p.re.MatchString(name)
+}
+
+// LastMatch returns the index of the last rule in rules that matches the branch
+// name, honoring negation, or -1 if none does
+func LastMatch(name string, rules []string) (int, error) {
+	match := -1
+	for i, rule := range rules {
+		p, err := CompilePattern(rule)
+		if err != nil {
+			return -1, err
+		}
+		if p.Match(name) != p.Negate {
+			match = i
+		}
+	}
+	return match, nil
+}
+
+// globToRegexp converts a glob to an anchored regular expression
+func globToRegexp(glob string) string {
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					// "**/" matches zero or more leading directories
+					i++
+					re.WriteString("(.*/)?")
+				} else {
+					re.WriteString(".*")
+				}
+			} else {
+				re.WriteString("[^/]*")
+			}
+		case '?':
+			re.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + class + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				re.WriteString(regexp.QuoteMeta(string(glob[i])))
+			} else {
+				re.WriteString(`\\`)
+			}
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	return re.String()
+}
+
+// BranchFilter decides which branches are shown, based on include_patterns and exclude_patterns
+type BranchFilter struct {
+	include []*Pattern
+	exclude []*Pattern
+}
+
+// NewBranchFilter compiles the include and exclude rules
+func NewBranchFilter(include []string, exclude []string) (*BranchFilter, error) {
+	f := &BranchFilter{}
+	for _, rule := range include {
+		p, err := CompilePattern(rule)
+		if err != nil {
+			return nil, fmt.Errorf("include_patterns: %w", err)
+		}
+		f.include = append(f.include, p)
+	}
+	for _, rule := range exclude {
+		p, err := CompilePattern(rule)
+		if err != nil {
+			return nil, fmt.Errorf("exclude_patterns: %w", err)
+		}
+		f.exclude = append(f.exclude, p)
+	}
+	return f, nil
+}
+
+// Explain reports whether a branch is hidden and, if so, which rule hid it.
+// Each list is evaluated in order and the last matching rule wins, so a later
+// "!rule" can bring back a branch hidden by an earlier one. When
+// include_patterns is set, a branch must be included before exclude_patterns
+// is considered.
+func (f *BranchFilter) Explain(name string) (hidden bool, reason string) {
+	if len(f.include) > 0 {
+		included := false
+		reason = "include_patterns: no pattern matched"
+		for _, p := range f.include {
+			if p.Match(name) {
+				included = !p.Negate
+				reason = fmt.Sprintf("include_patterns: %q", p.Source)
+			}
+		}
+		if !included {
+			return true, reason
+		}
+	}
+
+	excluded := false
+	for _, p := range f.exclude {
+		if p.Match(name) {
+			excluded = !p.Negate
+			reason = fmt.Sprintf("exclude_patterns: %q", p.Source)
+		}
+	}
+	if excluded {
+		return true, reason
+	}
+	return false, ""
+}
+
+// Hidden reports whether a branch is hidden by the filter
+func (f *BranchFilter) Hidden(name string) bool {
+	hidden, _ := f.Explain(name)
+	return hidden
+}
@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob  string
+		name  string
+		match bool
+	}{
+		{"release/*", "release/1.2", true},
+		{"release/*", "release/1.2/hotfix", false},
+		{"release/**", "release/1.2/hotfix", true},
+		{"**/dependabot/**", "dependabot/npm/x", true},
+		{"**/dependabot/**", "bots/dependabot/npm/x", true},
+		{"**/dependabot/**", "not-dependabot/x", false},
+		{"fix-?", "fix-1", true},
+		{"fix-?", "fix-12", false},
+		{"fix-?", "fix-/", false},
+		{"v[0-9].*", "v1.x", true},
+		{"v[0-9].*", "vx.x", false},
+		{"v[!0-9]*", "vx", true},
+		{"v[!0-9]*", "v1", false},
+		{"a[b", "a[b", true},
+		{`wip\*`, "wip*", true},
+		{`wip\*`, "wipx", false},
+		{"a.b", "axb", false},
+		{"feature", "feature/x", false},
+	}
+	for _, tt := range tests {
+		p, err := CompilePattern(tt.glob)
+		if err != nil {
+			t.Fatalf("CompilePattern(%q): %v", tt.glob, err)
+		}
+		if got := p.Match(tt.name); got != tt.match {
+			t.Errorf("%q (%s) matches %q = %v, want %v", tt.glob, globToRegexp(tt.glob), tt.name, got, tt.match)
+		}
+	}
+}
+
+func TestBranchFilterExplain(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		branch  string
+		hidden  bool
+		reason  string
+	}{
+		{"no patterns", nil, nil, "feature/a", false, ""},
+		{"excluded", nil, []string{"old/*"}, "old/a", true, `exclude_patterns: "old/*"`},
+		{"not excluded", nil, []string{"old/*"}, "new/a", false, ""},
+		{"last match wins", nil, []string{"old/*", "!old/keep-*"}, "old/keep-1", false, ""},
+		{"negation then exclusion", nil, []string{"old/*", "!old/keep-*", "old/keep-2"}, "old/keep-2", true, `exclude_patterns: "old/keep-2"`},
+		{"regexp", nil, []string{`re:^tmp-\d+$`}, "tmp-42", true, `exclude_patterns: "re:^tmp-\\d+$"`},
+		{"not included", []string{"feature/**"}, nil, "fix/a", true, "include_patterns: no pattern matched"},
+		{"included", []string{"feature/**"}, nil, "feature/a/b", false, ""},
+		{"included then negated", []string{"feature/**", "!feature/wip-*"}, nil, "feature/wip-1", true, `include_patterns: "!feature/wip-*"`},
+		{"included then excluded", []string{"feature/**"}, []string{"**/old"}, "feature/old", true, `exclude_patterns: "**/old"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewBranchFilter(tt.include, tt.exclude)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hidden, reason := f.Explain(tt.branch)
+			if hidden != tt.hidden || reason != tt.reason {
+				t.Errorf("Explain(%q) = %v, %q, want %v, %q", tt.branch, hidden, reason, tt.hidden, tt.reason)
+			}
+		})
+	}
+}
+
+func TestNewBranchFilterInvalid(t *testing.T) {
+	if _, err := NewBranchFilter(nil, []string{"re:("}); err == nil {
+		t.Error("an invalid regexp was accepted")
+	}
+}
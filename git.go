@@ -1,8 +1,8 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"os/exec"
 	"strings"
 	"time"
 )
@@ -16,44 +16,63 @@ type Branch struct {
 	LastCommit  string
 	Selected    bool
 	Status      string // "ok", "behind", "conflict", "updated"
+	Parent      string // branch this one is stacked on, if any
+	ParentSHA   string // tip of Parent when the stack was detected; the child's commits start after it
+	Depth       int    // nesting level in the stack tree
+	Stack       string // bottom branch of the stack this branch belongs to, empty if not stacked
+
+	Strategy       Strategy // how the branch is synced
+	StrategySource string   // the setting Strategy came from
+	Base           string   // base branch the branch is synced with and counted against
+	BaseSource     string   // the setting Base came from
+
+	PushRemote   string // remote the branch is pushed to
+	Unpushed     int    // commits not on the branch on PushRemote
+	RemoteAhead  int    // commits on the branch on PushRemote that the branch doesn't have
+	RemoteMoved  bool   // the remote branch has commits we haven't fetched, so RemoteAhead is a lower bound
+	OriginStatus string // how the branch compares with PushRemote, one of the Origin* statuses
+
+	Cleanup *CleanupCandidate // why the cleanup view suggests deleting the branch, if it does
 }
 
+// Errors returned by sync operations, used to classify why a branch failed
+var (
+	ErrFetchFailed      = errors.New("fetch failed")
+	ErrBaseUpdateFailed = errors.New("failed to update base branch")
+	ErrCheckoutFailed   = errors.New("failed to checkout")
+	ErrRebaseConflict   = errors.New("rebase conflict")
+	ErrPushFailed       = errors.New("push failed")
+	ErrMergeConflict    = errors.New("merge conflict")
+	ErrNotFastForward   = errors.New("not a fast-forward")
+)
+
 // IsGitRepo checks if current directory is a git repository
 func IsGitRepo() bool {
-	cmd := exec.Command("git", "rev-parse", "--git-dir")
-	err := cmd.Run()
-	return err == nil
+	return runGit("rev-parse", "--git-dir") == nil
 }
 
 // GetCurrentBranch returns the current branch name
 func GetCurrentBranch() (string, error) {
-	cmd := exec.Command("git", "branch", "--show-current")
-	output, err := cmd.Output()
-	if err != nil {
-		return "", err
-	}
-	return strings.TrimSpace(string(output)), nil
+	return gitOutput("branch", "--show-current")
 }
 
 // GetAllBranches returns all local branches
 func GetAllBranches() ([]string, error) {
-	cmd := exec.Command("git", "branch", "--format=%(refname:short)")
-	output, err := cmd.Output()
+	output, err := gitOutput("branch", "--format=%(refname:short)")
 	if err != nil {
 		return nil, err
 	}
-	branches := strings.Split(strings.TrimSpace(string(output)), "\n")
+	branches := strings.Split(output, "\n")
 	return branches, nil
 }
 
 // GetRemotes returns all configured remotes
 func GetRemotes() ([]string, error) {
-	cmd := exec.Command("git", "remote")
-	output, err := cmd.Output()
+	output, err := gitOutput("remote")
 	if err != nil {
 		return nil, err
 	}
-	remotes := strings.Split(strings.TrimSpace(string(output)), "\n")
+	remotes := strings.Split(output, "\n")
 	return remotes, nil
 }
 
@@ -63,11 +82,10 @@ func DetectBaseBranch() (string, error) {
 	upstream, err := DetectUpstreamRemote()
 	if err == nil {
 		// Try to get the HEAD branch from upstream remote
-		cmd := exec.Command("git", "remote", "show", upstream)
-		output, err := cmd.Output()
+		output, err := gitOutput("remote", "show", upstream)
 		if err == nil {
 			// Parse output to find "HEAD branch: <branch-name>"
-			lines := strings.Split(string(output), "\n")
+			lines := strings.Split(output, "\n")
 			for _, line := range lines {
 				line = strings.TrimSpace(line)
 				if strings.HasPrefix(line, "HEAD branch:") {
@@ -115,6 +133,18 @@ func DetectBaseBranch() (string, error) {
 	return "", fmt.Errorf("no branches found")
 }
 
+// ResolvePushRemote returns the remote a branch is pushed to, following git's own rules:
+// branch.<name>.pushRemote, then remote.pushDefault, then defaultRemote
+func ResolvePushRemote(branchName string, defaultRemote string) string {
+	if remote, err := gitOutput("config", "branch."+branchName+".pushRemote"); err == nil && remote != "" {
+		return remote
+	}
+	if remote, err := gitOutput("config", "remote.pushDefault"); err == nil && remote != "" {
+		return remote
+	}
+	return defaultRemote
+}
+
 // DetectUpstreamRemote tries to find upstream remote, falls back to origin
 func DetectUpstreamRemote() (string, error) {
 	remotes, err := GetRemotes()
@@ -138,27 +168,28 @@ func DetectUpstreamRemote() (string, error) {
 }
 
 // GetBranchInfo gets detailed info about a branch
-func GetBranchInfo(branchName string, baseBranch string, upstreamRemote string) (*Branch, error) {
+func GetBranchInfo(branchName string, config *Config) (*Branch, error) {
 	branch := &Branch{
 		Name:   branchName,
 		Status: "ok",
 	}
 	
-	// Get description from git config
+	// Get description, sync strategy and base branch from git config
 	branch.Description = GetBranchTag(branchName)
+	branch.Strategy, branch.StrategySource = config.ResolveStrategy(branchName)
+	branch.Base, branch.BaseSource = config.ResolveBase(branchName)
+	branch.PushRemote = config.PushRemote(branchName)
 	
 	// Get last commit date
-	cmd := exec.Command("git", "log", "-1", "--format=%ar", branchName)
-	output, err := cmd.Output()
+	output, err := gitOutput("log", "-1", "--format=%ar", branchName)
 	if err == nil {
-		branch.LastCommit = strings.TrimSpace(string(output))
+		branch.LastCommit = output
 	}
 	
 	// Get ahead/behind counts
-	cmd = exec.Command("git", "rev-list", "--left-right", "--count", fmt.Sprintf("%s/%s...%s", upstreamRemote, baseBranch, branchName))
-	output, err = cmd.Output()
+	output, err = gitOutput("rev-list", "--left-right", "--count", fmt.Sprintf("%s/%s...%s", config.UpstreamRemote, branch.Base, branchName))
 	if err == nil {
-		parts := strings.Fields(string(output))
+		parts := strings.Fields(output)
 		if len(parts) == 2 {
 			fmt.Sscanf(parts[0], "%d", &branch.Behind)
 			fmt.Sscanf(parts[1], "%d", &branch.Ahead)
@@ -169,126 +200,95 @@ func GetBranchInfo(branchName string, baseBranch string, upstreamRemote string)
 		}
 	}
 	
+	// Get unpushed/remote-only counts against the push remote
+	readOriginCounts(branch)
+	
 	return branch, nil
 }
 
 // FetchUpstream fetches the upstream remote
 func FetchUpstream(remote string, baseBranch string) error {
-	cmd := exec.Command("git", "fetch", remote, baseBranch)
-	return cmd.Run()
+	return runGit("fetch", remote, baseBranch)
 }
 
-// UpdateBaseBranch updates the local base branch from upstream
-func UpdateBaseBranch(baseBranch string, remote string) error {
+// UpdateBaseBranch updates the local base branch from upstream and pushes it to pushRemote
+func UpdateBaseBranch(baseBranch string, remote string, pushRemote string) error {
 	// Check if the local base branch has diverged from the remote
-	cmd := exec.Command("git", "rev-list", baseBranch, fmt.Sprintf("^%s/%s", remote, baseBranch))
-	output, err := cmd.Output()
+	output, err := gitOutput("rev-list", baseBranch, fmt.Sprintf("^%s/%s", remote, baseBranch))
 	if err != nil {
 		return fmt.Errorf("could not check for branch divergence: %w", err)
 	}
-	if len(strings.TrimSpace(string(output))) > 0 {
+	if len(output) > 0 {
 		return fmt.Errorf("local base branch '%s' has diverged from '%s/%s'. Please resolve manually", baseBranch, remote, baseBranch)
 	}
 
-	// Checkout base branch
-	cmd = exec.Command("git", "checkout", baseBranch)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to checkout %s: %w", baseBranch, err)
-	}
-	
-	// Reset to upstream
-	cmd = exec.Command("git", "reset", "--hard", fmt.Sprintf("%s/%s", remote, baseBranch))
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to reset to %s/%s: %w", remote, baseBranch, err)
+	// Fast-forward to upstream without checking anything out. If the base branch is
+	// checked out in a worktree, fast-forward it there so its files stay in sync.
+	upstreamRef := fmt.Sprintf("%s/%s", remote, baseBranch)
+	if path, ok := worktreeFor(baseBranch); ok {
+		if err := gitCombined("-C", path, "merge", "--ff-only", upstreamRef); err != nil {
+			return fmt.Errorf("failed to fast-forward %s to %s: %w", baseBranch, upstreamRef, err)
+		}
+	} else {
+		if err := gitCombined("update-ref", "-m", "gitsync: fast-forward to "+upstreamRef, "refs/heads/"+baseBranch, upstreamRef); err != nil {
+			return fmt.Errorf("failed to update %s to %s: %w", baseBranch, upstreamRef, err)
+		}
 	}
 	
-	// Push to origin
-	cmd = exec.Command("git", "push", "origin", baseBranch, "--force-with-lease")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to push to origin: %w", err)
+	// Push to the push remote
+	if err := runGit("push", pushRemote, baseBranch, "--force-with-lease"); err != nil {
+		return fmt.Errorf("failed to push to %s: %w", pushRemote, err)
 	}
 	
 	return nil
 }
 
-// RebaseBranch rebases a branch onto the base branch
-func RebaseBranch(branchName string, baseBranch string) error {
-	// Checkout the branch
-	cmd := exec.Command("git", "checkout", branchName)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to checkout: %w", err)
-	}
-	
-	// Rebase onto base branch
-	cmd = exec.Command("git", "rebase", baseBranch)
-	if err := cmd.Run(); err != nil {
-		// Abort the rebase
-		abortCmd := exec.Command("git", "rebase", "--abort")
-		abortCmd.Run()
-		return fmt.Errorf("rebase conflict")
-	}
-	
-	return nil
+// PushBranch force-pushes (with lease) a branch to the given remote
+func PushBranch(branchName string, remote string) error {
+	return runGit("push", remote, branchName, "--force-with-lease")
 }
 
-// PushBranch pushes a branch to origin
-func PushBranch(branchName string) error {
-	cmd := exec.Command("git", "push", "origin", branchName, "--force-with-lease")
-	return cmd.Run()
+// PushFastForward pushes a branch without forcing, so the remote rejects it
+// unless it only adds commits
+func PushFastForward(branchName string, remote string) error {
+	return runGit("push", remote, branchName)
 }
 
-// DeleteLocalBranch deletes a local branch
-func DeleteLocalBranch(branchName string) error {
-	cmd := exec.Command("git", "branch", "-d", branchName)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf(strings.TrimSpace(string(output)))
+// DeleteLocalBranch deletes a local branch. Unless force is set, git refuses
+// to delete a branch that isn't merged into its upstream or HEAD.
+func DeleteLocalBranch(branchName string, force bool) error {
+	if force {
+		return gitCombined("branch", "-D", branchName)
 	}
-	return nil
+	return gitCombined("branch", "-d", branchName)
 }
 
-// DeleteRemoteBranch deletes a remote branch
-func DeleteRemoteBranch(branchName string) error {
-	cmd := exec.Command("git", "push", "origin", "--delete", branchName)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf(strings.TrimSpace(string(output)))
-	}
-	return nil
+// DeleteRemoteBranch deletes a branch on the given remote
+func DeleteRemoteBranch(branchName string, remote string) error {
+	return gitCombined("push", remote, "--delete", branchName)
 }
 
 // StashChanges stashes the current changes
 func StashChanges() error {
-	cmd := exec.Command("git", "stash")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf(strings.TrimSpace(string(output)))
-	}
-	return nil
+	return gitCombined("stash")
 }
 
 // StashPop pops the latest stash
 func StashPop() error {
-	cmd := exec.Command("git", "stash", "pop")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf(strings.TrimSpace(string(output)))
-	}
-	return nil
+	return gitCombined("stash", "pop")
 }
 
 // HasUncommittedChanges checks if there are uncommitted changes
 func HasUncommittedChanges() bool {
-	cmd := exec.Command("git", "status", "--porcelain", "-uno")
-	output, err := cmd.Output()
+	output, err := gitOutput("status", "--porcelain", "-uno")
 	if err != nil {
 		return false
 	}
-	return len(strings.TrimSpace(string(output))) > 0
+	return len(output) > 0
 }
 
 // GetBranchesWithInfo gets all branches with their info
-func GetBranchesWithInfo(baseBranch string, upstreamRemote string, excludePatterns []string) ([]*Branch, error) {
+func GetBranchesWithInfo(config *Config, filter *BranchFilter) ([]*Branch, error) {
 	branchNames, err := GetAllBranches()
 	if err != nil {
 		return nil, err
@@ -297,30 +297,48 @@ func GetBranchesWithInfo(baseBranch string, upstreamRemote string, excludePatter
 	var branches []*Branch
 	for _, name := range branchNames {
 		// Skip base branch
-		if name == baseBranch {
+		if name == config.BaseBranch {
 			continue
 		}
 		
-		// Skip excluded patterns
-		skip := false
-		for _, pattern := range excludePatterns {
-			if strings.Contains(name, pattern) {
-				skip = true
-				break
-			}
-		}
-		if skip {
+		// Skip branches hidden by include/exclude patterns
+		if filter.Hidden(name) {
 			continue
 		}
 		
-		branch, err := GetBranchInfo(name, baseBranch, upstreamRemote)
+		branch, err := GetBranchInfo(name, config)
 		if err != nil {
 			continue
 		}
 		branches = append(branches, branch)
 	}
 	
-	return branches, nil
+	return DetectStacks(branches), nil
+}
+
+// HiddenBranch is a branch hidden by the include/exclude patterns
+type HiddenBranch struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"` // the rule that hid the branch
+}
+
+// GetHiddenBranches returns the branches hidden by the filter and the rule that hid each one
+func GetHiddenBranches(baseBranch string, filter *BranchFilter) ([]HiddenBranch, error) {
+	branchNames, err := GetAllBranches()
+	if err != nil {
+		return nil, err
+	}
+
+	var hidden []HiddenBranch
+	for _, name := range branchNames {
+		if name == baseBranch {
+			continue
+		}
+		if isHidden, reason := filter.Explain(name); isHidden {
+			hidden = append(hidden, HiddenBranch{Name: name, Reason: reason})
+		}
+	}
+	return hidden, nil
 }
 
 // Sleep for a bit to show messages
@@ -330,20 +348,10 @@ func Sleep(ms int) {
 
 // CheckoutBranch checks out an existing branch
 func CheckoutBranch(branchName string) error {
-	cmd := exec.Command("git", "checkout", branchName)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf(strings.TrimSpace(string(output)))
-	}
-	return nil
+	return gitCombined("checkout", branchName)
 }
 
 // CreateAndCheckoutBranch creates and checks out a new branch from a base branch
 func CreateAndCheckoutBranch(newBranchName string, fromBranch string) error {
-	cmd := exec.Command("git", "checkout", "-b", newBranchName, fromBranch)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf(strings.TrimSpace(string(output)))
-	}
-	return nil
+	return gitCombined("checkout", "-b", newBranchName, fromBranch)
 }
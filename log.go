@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultLogFormat is the log_format used when the config doesn't set one
+const DefaultLogFormat = "--graph --pretty=format:'%C(yellow)%h%C(reset) - %s %C(green)(%cr) %C(bold blue)<%an>%C(reset)'"
+
+// logFullLimit caps the full history of a branch, so huge repositories stay responsive
+const logFullLimit = 2000
+
+// ansiEscape matches the color codes of 'git log --color'
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// BranchLog returns the lines of 'git log' for a branch, formatted with the
+// log_format options and colored. By default only the branch's own commits
+// are shown (base..branch); full shows its whole history.
+func BranchLog(config *Config, branch *Branch, full bool) ([]string, error) {
+	options, err := LogOptions(config.LogFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	args := append([]string{"log", "--color=always"}, options...)
+	if full {
+		args = append(args, fmt.Sprintf("--max-count=%d", logFullLimit), "refs/heads/"+branch.Name)
+	} else {
+		args = append(args, config.UpstreamRemote+"/"+branch.Base+"..refs/heads/"+branch.Name)
+	}
+	lines, err := gitLines(append(args, "--")...)
+	if err != nil {
+		return nil, fmt.Errorf("git log failed (check log_format): %w", err)
+	}
+	return lines, nil
+}
+
+// LogOptions splits log_format into 'git log' options. Words are separated by
+// spaces and can be quoted with single or double quotes, like in a shell.
+// Options that write files are refused, since log_format can come from a
+// checked-in .gitsync.yaml.
+func LogOptions(format string) ([]string, error) {
+	if strings.TrimSpace(format) == "" {
+		format = DefaultLogFormat
+	}
+
+	var options []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	for _, r := range format {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				options = append(options, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("log_format has an unterminated %c quote", quote)
+	}
+	if inWord {
+		options = append(options, word.String())
+	}
+
+	for _, option := range options {
+		if option == "--output" || strings.HasPrefix(option, "--output=") {
+			return nil, fmt.Errorf("log_format can't use %s", option)
+		}
+	}
+	return options, nil
+}
+
+// stripANSI removes color codes from a line of 'git log' output
+func stripANSI(line string) string {
+	return ansiEscape.ReplaceAllString(line, "")
+}
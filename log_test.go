@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLogOptions(t *testing.T) {
+	tests := []struct {
+		format  string
+		want    []string
+		wantErr bool
+	}{
+		{"--oneline --decorate", []string{"--oneline", "--decorate"}, false},
+		{"  --oneline\t--graph  ", []string{"--oneline", "--graph"}, false},
+		{"--pretty=format:'%h %s'", []string{"--pretty=format:%h %s"}, false},
+		{`--pretty=format:"%h 'quoted'"`, []string{"--pretty=format:%h 'quoted'"}, false},
+		{"--format=''", []string{"--format="}, false},
+		{"", []string{"--graph", "--pretty=format:%C(yellow)%h%C(reset) - %s %C(green)(%cr) %C(bold blue)<%an>%C(reset)"}, false},
+		{"-p --output-indicator-new=+ --output-indicator-old=-", []string{"-p", "--output-indicator-new=+", "--output-indicator-old=-"}, false},
+		{"--oneline 'unterminated", nil, true},
+		{"--output=/tmp/log", nil, true},
+		{"--oneline --output /tmp/log", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := LogOptions(tt.format)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("LogOptions(%q) = %q, %v, want %q (error: %v)", tt.format, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	if got := stripANSI("\x1b[33mabc1234\x1b[m - fix \x1b[1;34m<me>\x1b[0m"); got != "abc1234 - fix <me>" {
+		t.Errorf("stripANSI = %q", got)
+	}
+}
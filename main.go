@@ -8,12 +8,23 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-var manualMode bool
+var (
+	manualMode      bool
+	fetchOnStart    bool
+	showCommandLog  bool
+	pauseOnConflict bool
+	configPath      string
+)
 
 func main() {
 	// Parse flags
 	flag.BoolVar(&manualMode, "m", false, "Manual mode - ask for confirmation at each step")
 	flag.BoolVar(&manualMode, "manual", false, "Manual mode - ask for confirmation at each step")
+	flag.BoolVar(&fetchOnStart, "fetch-on-start", true, "Fetch the base branches when the TUI starts and reloads (--fetch-on-start=false to skip)")
+	flag.BoolVar(&showCommandLog, "show-command-log", true, "Show the git commands an update or delete runs (--show-command-log=false to hide)")
+	flag.BoolVar(&pauseOnConflict, "pause-on-conflict", false, "Pause on rebase conflicts so they can be resolved, instead of aborting the branch")
+	flag.StringVar(&configPath, "config", "", "Read the repository's settings from this file instead of .gitsync.yaml at the repository root")
+	flag.Usage = printUsage
 	flag.Parse()
 
 	// Check if we're in a git repo
@@ -22,9 +33,36 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Whatever happens, never leave the user on another branch with their changes stashed
+	defer func() {
+		if r := recover(); r != nil {
+			if err := RestoreActive(); err != nil {
+				fmt.Printf("❌ %v\n", err)
+			}
+			panic(r)
+		}
+	}()
+
+	// Run a headless subcommand if one was given
+	if flag.NArg() > 0 {
+		c := findCommand(flag.Arg(0))
+		if c == nil {
+			fmt.Printf("❌ Unknown command '%s'\n\n", flag.Arg(0))
+			printUsage()
+			os.Exit(exitFatal)
+		}
+		os.Exit(c.run(c, flag.Args()[1:]))
+	}
+
 	// Run the TUI
 	p := tea.NewProgram(InitialModel(), tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	final, err := p.Run()
+	if restoreErr := RestoreActive(); restoreErr != nil {
+		fmt.Printf("❌ %v\n", restoreErr)
+	} else if m, ok := final.(Model); ok && m.restoreErr != "" {
+		fmt.Printf("❌ %s\n", m.restoreErr)
+	}
+	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
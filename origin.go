@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Origin statuses: how a branch compares with its copy on its push remote
+const (
+	OriginInSync    = "in-sync"
+	OriginLocalOnly = "local-only"           // never pushed (or never fetched)
+	OriginUnpushed  = "unpushed"             // has commits the remote branch doesn't
+	OriginAhead     = "remote-ahead"         // the remote branch has commits the branch doesn't
+	OriginDiverged  = "diverged-from-origin" // both
+)
+
+// OriginStatuses lists every origin status, in the order the TUI filter cycles through them
+var OriginStatuses = []string{OriginUnpushed, OriginAhead, OriginDiverged, OriginLocalOnly, OriginInSync}
+
+// originStatus derives the origin status from the counts against the remote branch
+func originStatus(unpushed int, remoteAhead int, remoteMoved bool) string {
+	ahead := remoteAhead > 0 || remoteMoved
+	switch {
+	case unpushed > 0 && ahead:
+		return OriginDiverged
+	case unpushed > 0:
+		return OriginUnpushed
+	case ahead:
+		return OriginAhead
+	}
+	return OriginInSync
+}
+
+// readOriginCounts counts the branch's commits against its remote-tracking ref
+// on the push remote. It doesn't touch the network.
+func readOriginCounts(branch *Branch) {
+	tracking := "refs/remotes/" + branch.PushRemote + "/" + branch.Name
+	output, err := gitOutput("rev-list", "--left-right", "--count", tracking+"...refs/heads/"+branch.Name)
+	if err != nil {
+		branch.OriginStatus = OriginLocalOnly
+		return
+	}
+	if parts := strings.Fields(output); len(parts) == 2 {
+		fmt.Sscanf(parts[0], "%d", &branch.RemoteAhead)
+		fmt.Sscanf(parts[1], "%d", &branch.Unpushed)
+	}
+	branch.OriginStatus = originStatus(branch.Unpushed, branch.RemoteAhead, false)
+}
+
+// CheckRemoteHeads asks each push remote (git ls-remote) where its branches
+// are, to catch pushes made since the remote was last fetched. Remote-tracking
+// refs are left alone: fetching them would move the expected value of
+// --force-with-lease and let a sync overwrite those pushes. Remotes that can't
+// be reached are ignored.
+func CheckRemoteHeads(branches []*Branch) {
+	remotes := &remoteHeads{}
+	for _, b := range branches {
+		heads, err := remotes.list(b.PushRemote)
+		if err != nil {
+			continue
+		}
+		sha, ok := heads["refs/heads/"+b.Name]
+		if !ok {
+			continue
+		}
+		tracking, _ := gitOutput("rev-parse", "-q", "--verify", "refs/remotes/"+b.PushRemote+"/"+b.Name)
+		if sha == tracking {
+			continue
+		}
+
+		if runGit("cat-file", "-e", sha+"^{commit}") == nil {
+			// We have the commit (e.g. pushed from another worktree), so count against it
+			output, err := gitOutput("rev-list", "--left-right", "--count", sha+"...refs/heads/"+b.Name)
+			if parts := strings.Fields(output); err == nil && len(parts) == 2 {
+				fmt.Sscanf(parts[0], "%d", &b.RemoteAhead)
+				fmt.Sscanf(parts[1], "%d", &b.Unpushed)
+			}
+		} else {
+			b.RemoteMoved = true
+		}
+		b.OriginStatus = originStatus(b.Unpushed, b.RemoteAhead, b.RemoteMoved)
+	}
+}
+
+// HasStatus reports whether the branch's base status or origin status is status
+func (b *Branch) HasStatus(status string) bool {
+	return b.Status == status || b.OriginStatus == status
+}
+
+// ParseStatuses validates a comma-separated list of statuses for filtering
+func ParseStatuses(list string) ([]string, error) {
+	valid := append([]string{"ok", "behind"}, OriginStatuses...)
+	var statuses []string
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		found := false
+		for _, v := range valid {
+			if s == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown status %q (use %s)", s, strings.Join(valid, ", "))
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// originBadge renders the origin counts, e.g. "⇡2⇣1", or "" when in sync
+func originBadge(b *Branch) string {
+	if b.OriginStatus == OriginLocalOnly {
+		return "(not pushed)"
+	}
+	badge := ""
+	if b.Unpushed > 0 {
+		badge += fmt.Sprintf("⇡%d", b.Unpushed)
+	}
+	switch {
+	case b.RemoteMoved && b.RemoteAhead == 0:
+		badge += "⇣?"
+	case b.RemoteMoved:
+		badge += fmt.Sprintf("⇣%d+", b.RemoteAhead)
+	case b.RemoteAhead > 0:
+		badge += fmt.Sprintf("⇣%d", b.RemoteAhead)
+	}
+	return badge
+}
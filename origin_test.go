@@ -0,0 +1,64 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOriginStatus(t *testing.T) {
+	tests := []struct {
+		unpushed    int
+		remoteAhead int
+		remoteMoved bool
+		want        string
+	}{
+		{0, 0, false, OriginInSync},
+		{2, 0, false, OriginUnpushed},
+		{0, 1, false, OriginAhead},
+		{0, 0, true, OriginAhead},
+		{2, 1, false, OriginDiverged},
+		{2, 0, true, OriginDiverged},
+	}
+	for _, tt := range tests {
+		if got := originStatus(tt.unpushed, tt.remoteAhead, tt.remoteMoved); got != tt.want {
+			t.Errorf("originStatus(%d, %d, %v) = %s, want %s", tt.unpushed, tt.remoteAhead, tt.remoteMoved, got, tt.want)
+		}
+	}
+}
+
+func TestReadOriginCounts(t *testing.T) {
+	fake := useFakeRunner(t)
+	fake.On("rev-list --left-right --count refs/remotes/fork/a...refs/heads/a", "1\t2\n", nil)
+	fake.On("rev-list --left-right --count refs/remotes/fork/b...refs/heads/b", "", errNoRef)
+
+	a := &Branch{Name: "a", PushRemote: "fork"}
+	readOriginCounts(a)
+	if a.RemoteAhead != 1 || a.Unpushed != 2 || a.OriginStatus != OriginDiverged {
+		t.Errorf("a: ahead %d, unpushed %d, %s, want 1, 2, %s", a.RemoteAhead, a.Unpushed, a.OriginStatus, OriginDiverged)
+	}
+	b := &Branch{Name: "b", PushRemote: "fork"}
+	readOriginCounts(b)
+	if b.OriginStatus != OriginLocalOnly {
+		t.Errorf("b: %s, want %s", b.OriginStatus, OriginLocalOnly)
+	}
+}
+
+func TestParseStatuses(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"behind", []string{"behind"}, false},
+		{"ok, unpushed,,diverged-from-origin", []string{"ok", "unpushed", "diverged-from-origin"}, false},
+		{"behind,conflicted", nil, true},
+		{"Behind", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseStatuses(tt.list)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseStatuses(%q) = %v, %v, want %v (error: %v)", tt.list, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SyncPlan is what a sync would do, computed without changing any refs
+type SyncPlan struct {
+	Base       BasePlan     `json:"base"`
+	OtherBases []BasePlan   `json:"other_bases,omitempty"` // base branches set per branch, in the order they are used
+	Branches   []BranchPlan `json:"branches"`
+}
+
+// BasePlan describes how a base branch would be updated
+type BasePlan struct {
+	Name     string     `json:"name"`
+	From     string     `json:"from"`     // local base branch, empty if there is none: branches are then synced onto the upstream one
+	To       string     `json:"to"`       // upstream base branch
+	Commits  int        `json:"commits"`  // commits the base branch would fast-forward by
+	Diverged bool       `json:"diverged"` // the local base branch has commits upstream doesn't; the sync would stop
+	Push     LeaseCheck `json:"push"`
+}
+
+// BranchPlan describes how a branch would be rebased (merged, fast-forwarded) and pushed
+type BranchPlan struct {
+	Name           string     `json:"name"`
+	Strategy       Strategy   `json:"strategy"`
+	StrategySource string     `json:"strategy_source"`          // the setting the strategy came from
+	Onto           string     `json:"onto"`                     // the upstream base branch, or the parent of a stacked branch
+	Commits        []string   `json:"commits"`                  // commits that would be replayed, merged in or fast-forwarded over ("sha subject"), oldest first
+	UpToDate       bool       `json:"up_to_date"`               // already based on what it would be rebased onto
+	Conflict       bool       `json:"conflict"`                 // a conflict is predicted
+	ConflictFiles  []string   `json:"conflict_files,omitempty"` // files predicted to conflict
+	Unpredictable  string     `json:"unpredictable,omitempty"`  // why conflicts could not be predicted
+	InPlace        bool       `json:"in_place"`                 // rebased in the current checkout rather than a temporary worktree
+	Blocked        string     `json:"blocked,omitempty"`        // why the branch can't be synced at all
+	Push           LeaseCheck `json:"push"`
+}
+
+// LeaseCheck predicts whether a --force-with-lease push would be accepted. The
+// lease holds while the remote branch is still where our remote-tracking ref says.
+// For a plain push (FastForward) the remote branch must instead be contained
+// in what is pushed.
+type LeaseCheck struct {
+	Remote      string `json:"remote"`
+	Expected    string `json:"expected"` // remote-tracking ref, empty if there is none
+	Actual      string `json:"actual"`   // branch on the remote, empty if it doesn't exist there
+	FastForward bool   `json:"fast_forward"`
+	Rejected    bool   `json:"rejected"`
+	Error       string `json:"error,omitempty"` // the remote could not be queried
+}
+
+// AllBases returns the default base branch followed by the other base branches
+func (p *SyncPlan) AllBases() []BasePlan {
+	return append([]BasePlan{p.Base}, p.OtherBases...)
+}
+
+// Failures counts the steps the plan predicts will fail
+func (p *SyncPlan) Failures() int {
+	failures := 0
+	for _, base := range p.AllBases() {
+		if base.Diverged || base.Push.Rejected {
+			failures++
+		}
+	}
+	for _, b := range p.Branches {
+		if b.WouldFail() {
+			failures++
+		}
+	}
+	return failures
+}
+
+// WouldFail reports whether syncing the branch is predicted to fail
+func (b BranchPlan) WouldFail() bool {
+	return b.Conflict || b.Blocked != "" || b.Push.Rejected
+}
+
+// Describe summarizes the lease check, e.g. "push to origin ✓"
+func (l LeaseCheck) Describe() string {
+	switch {
+	case l.Error != "":
+		return fmt.Sprintf("push to %s: unknown (%s)", l.Remote, l.Error)
+	case !l.Rejected:
+		return fmt.Sprintf("push to %s ✓", l.Remote)
+	case l.FastForward:
+		return fmt.Sprintf("push to %s ✗ would be rejected (remote is at %s, which has commits the branch doesn't)", l.Remote, shortSHA(l.Actual))
+	case l.Actual == "":
+		return fmt.Sprintf("push to %s ✗ lease would be rejected (deleted on the remote)", l.Remote)
+	case l.Expected == "":
+		return fmt.Sprintf("push to %s ✗ lease would be rejected (exists on the remote at %s, never fetched)", l.Remote, shortSHA(l.Actual))
+	default:
+		return fmt.Sprintf("push to %s ✗ lease would be rejected (remote is at %s, expected %s)", l.Remote, shortSHA(l.Actual), shortSHA(l.Expected))
+	}
+}
+
+// PlanSync works out what syncing the given branches would do against the
+// already fetched upstream base branches. It only reads: no ref is changed.
+func PlanSync(config *Config, branches []*Branch) (*SyncPlan, error) {
+	remotes := &remoteHeads{}
+	plan := &SyncPlan{}
+
+	upstreams := map[string]string{}
+	for i, base := range RunBases(config, branches) {
+		basePlan, err := planBase(base, config, remotes)
+		if err != nil && i == 0 {
+			return nil, err
+		}
+		if err != nil {
+			// The branches on it are reported as blocked
+			continue
+		}
+		upstreams[base] = basePlan.To
+		if i == 0 {
+			plan.Base = basePlan
+		} else {
+			plan.OtherBases = append(plan.OtherBases, basePlan)
+		}
+	}
+
+	// Stacked branches are predicted against the upstream of their stack's bottom branch
+	stackBase := map[string]string{}
+	for _, b := range branches {
+		base := b.Base
+		if base == "" {
+			base = config.BaseBranch
+		}
+		if b.Parent != "" {
+			base = stackBase[b.Parent]
+		}
+		stackBase[b.Name] = base
+
+		upstreamRef := fmt.Sprintf("%s/%s", config.UpstreamRemote, base)
+		upstream, ok := upstreams[base]
+		if !ok && b.Strategy != StrategySkip {
+			plan.Branches = append(plan.Branches, BranchPlan{
+				Name:           b.Name,
+				Strategy:       b.Strategy,
+				StrategySource: b.StrategySource,
+				Onto:           upstreamRef,
+				Commits:        []string{},
+				Blocked:        fmt.Sprintf("'%s' has not been fetched", upstreamRef),
+			})
+			continue
+		}
+		plan.Branches = append(plan.Branches, planBranch(b, upstream, upstreamRef, config, remotes))
+	}
+	return plan, nil
+}
+
+// planBase predicts the fast-forward of a local base branch to its upstream
+func planBase(base string, config *Config, remotes *remoteHeads) (BasePlan, error) {
+	upstreamRef := fmt.Sprintf("%s/%s", config.UpstreamRemote, base)
+	upstream, err := gitOutput("rev-parse", "--verify", "refs/remotes/"+upstreamRef+"^{commit}")
+	if err != nil {
+		return BasePlan{}, fmt.Errorf("%w: '%s' has not been fetched", ErrFetchFailed, upstreamRef)
+	}
+
+	p := BasePlan{Name: base, To: upstream}
+	local, err := gitOutput("rev-parse", "--verify", "-q", "refs/heads/"+base)
+	if err != nil {
+		return p, nil
+	}
+	p.From = local
+	if ahead, _ := gitOutput("rev-list", "--count", upstream+".."+local); ahead != "0" {
+		p.Diverged = true
+	}
+	count, _ := gitOutput("rev-list", "--count", local+".."+upstream)
+	fmt.Sscanf(count, "%d", &p.Commits)
+	p.Push = remotes.leaseCheck(config.PushRemote(base), base)
+	return p, nil
+}
+
+// planBranch predicts the sync of a single branch with upstream, using the
+// branch's strategy. A stacked branch replays only its own commits onto its
+// parent; its conflicts are predicted against upstream together with the
+// parent's commits.
+func planBranch(branch *Branch, upstream string, upstreamRef string, config *Config, remotes *remoteHeads) BranchPlan {
+	name := branch.Name
+	b := BranchPlan{Name: name, Strategy: branch.Strategy, StrategySource: branch.StrategySource, Onto: upstreamRef, Commits: []string{}}
+	if branch.Strategy == StrategySkip {
+		return b
+	}
+
+	if NeedsInPlaceRebase(name) {
+		b.InPlace = true
+	} else if path, ok := checkedOutElsewhere(name); ok {
+		b.Blocked = fmt.Sprintf("checked out in worktree %s", path)
+	}
+
+	onto := upstream
+	if branch.Parent != "" {
+		b.Onto = branch.Parent
+		onto = branch.ParentSHA
+	}
+
+	// The commits rebase would replay: not merges, and not already upstream under another SHA
+	commitRange := []string{"--cherry-pick", "--right-only", upstream + "..." + name}
+	if branch.Parent != "" {
+		commitRange = []string{branch.ParentSHA + ".." + name}
+	}
+	// A merge or fast-forward brings in the commits the branch doesn't have yet
+	remote := config.PushRemote(name)
+	switch branch.Strategy {
+	case StrategyMerge:
+		commitRange = []string{name + ".." + onto}
+		b.Push = remotes.fastForwardCheck(remote, name, name)
+	case StrategyFFOnly:
+		commitRange = []string{name + ".." + onto}
+		b.Push = remotes.fastForwardCheck(remote, name, onto)
+	default:
+		b.Push = remotes.leaseCheck(remote, name)
+	}
+	args := append([]string{"log", "--reverse", "--no-merges", "--format=%h %s"}, commitRange...)
+	if output, err := gitOutput(args...); err == nil && output != "" {
+		b.Commits = strings.Split(output, "\n")
+	}
+
+	if runGit("merge-base", "--is-ancestor", upstream, name) == nil {
+		b.UpToDate = true
+		return b
+	}
+
+	if branch.Strategy == StrategyFFOnly {
+		if own, _ := gitOutput("rev-list", "--count", onto+".."+name); own != "0" && b.Blocked == "" {
+			b.Blocked = fmt.Sprintf("has %s commit(s) %s doesn't, can't fast-forward", own, b.Onto)
+		}
+		return b
+	}
+
+	b.ConflictFiles, b.Unpredictable = predictConflicts(upstream, name)
+	b.Conflict = len(b.ConflictFiles) > 0
+	return b
+}
+
+// objectID matches a full SHA-1 or SHA-256 object name
+var objectID = regexp.MustCompile(`^[0-9a-f]{40}([0-9a-f]{24})?$`)
+
+// predictConflicts merges branch into base in memory (git merge-tree --write-tree)
+// and returns the conflicting files. The rebase replays commits one by one, so
+// this is a prediction: it can miss conflicts that a later commit resolves.
+func predictConflicts(base string, branch string) (files []string, unpredictable string) {
+	output, err := runner.Output("merge-tree", "--write-tree", "--name-only", "--no-messages", base, branch)
+	if err == nil {
+		return nil, ""
+	}
+
+	// On a conflict, git still prints the merged tree first, then one conflicted
+	// file per line; when it fails outright (an older git, a bad ref) it prints nothing
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if !objectID.MatchString(lines[0]) {
+		return nil, "git merge-tree --write-tree failed (needs git 2.38 or later)"
+	}
+	for _, line := range lines[1:] {
+		if line == "" {
+			break
+		}
+		files = append(files, line)
+	}
+	if len(files) == 0 {
+		files = []string{"(unknown file)"}
+	}
+	return files, ""
+}
+
+// remoteHeads caches 'git ls-remote --heads' per remote
+type remoteHeads struct {
+	heads map[string]map[string]string
+	errs  map[string]error
+}
+
+// leaseCheck compares a branch on the remote with our remote-tracking ref for it
+func (r *remoteHeads) leaseCheck(remote string, branchName string) LeaseCheck {
+	l := LeaseCheck{Remote: remote}
+	l.Expected, _ = gitOutput("rev-parse", "--verify", "-q", "refs/remotes/"+remote+"/"+branchName)
+
+	heads, err := r.list(remote)
+	if err != nil {
+		l.Error = err.Error()
+		return l
+	}
+	l.Actual = heads["refs/heads/"+branchName]
+	l.Rejected = l.Actual != l.Expected
+	return l
+}
+
+// fastForwardCheck predicts whether a plain push would be accepted: the branch
+// on the remote must already be contained in tip, the commit being pushed
+func (r *remoteHeads) fastForwardCheck(remote string, branchName string, tip string) LeaseCheck {
+	l := r.leaseCheck(remote, branchName)
+	l.FastForward = true
+	if l.Error == "" {
+		l.Rejected = l.Actual != "" && runGit("merge-base", "--is-ancestor", l.Actual, tip) != nil
+	}
+	return l
+}
+
+// list returns the branches on a remote, mapped to their SHAs
+func (r *remoteHeads) list(remote string) (map[string]string, error) {
+	if r.heads == nil {
+		r.heads = map[string]map[string]string{}
+		r.errs = map[string]error{}
+	}
+	if heads, ok := r.heads[remote]; ok {
+		return heads, r.errs[remote]
+	}
+
+	heads := map[string]string{}
+	output, err := gitOutput("ls-remote", "--heads", remote)
+	if err != nil {
+		err = fmt.Errorf("could not reach %s", remote)
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if parts := strings.Fields(line); len(parts) == 2 {
+			heads[parts[1]] = parts[0]
+		}
+	}
+	r.heads[remote] = heads
+	r.errs[remote] = err
+	return heads, err
+}
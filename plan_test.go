@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPredictConflicts(t *testing.T) {
+	tree := "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+	tests := []struct {
+		name          string
+		output        string
+		err           error
+		files         []string
+		unpredictable bool
+	}{
+		{"clean merge", tree + "\n", nil, nil, false},
+		{"conflicts", tree + "\na.go\ndir/b.go\n", errNoRef, []string{"a.go", "dir/b.go"}, false},
+		{"conflict without file names", tree + "\n", errNoRef, []string{"(unknown file)"}, false},
+		{"merge-tree failed", "", errNoRef, nil, true},
+		{"old git", "usage: git merge-tree <base-tree> <branch1> <branch2>\n", errNoRef, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeRunner(t)
+			fake.On("merge-tree --write-tree --name-only --no-messages upstream/main feat", tt.output, tt.err)
+
+			files, unpredictable := predictConflicts("upstream/main", "feat")
+			if !reflect.DeepEqual(files, tt.files) || (unpredictable != "") != tt.unpredictable {
+				t.Errorf("predictConflicts = %q, %q, want %q (unpredictable: %v)", files, unpredictable, tt.files, tt.unpredictable)
+			}
+		})
+	}
+}
+
+func TestLeaseCheck(t *testing.T) {
+	fake := useFakeRunner(t)
+	fake.On("ls-remote --heads origin", strings.Join([]string{
+		oldSHA + "\trefs/heads/same",
+		newSHA + "\trefs/heads/moved",
+		newSHA + "\trefs/heads/unfetched",
+	}, "\n"), nil)
+	fake.On("ls-remote --heads offline", "", errNoRef)
+	for _, name := range []string{"same", "moved", "deleted"} {
+		fake.On("rev-parse --verify -q refs/remotes/origin/"+name, oldSHA+"\n", nil)
+	}
+	fake.On("rev-parse --verify -q refs/remotes/origin/unfetched", "", errNoRef)
+
+	tests := []struct {
+		remote string
+		branch string
+		want   LeaseCheck
+	}{
+		{"origin", "same", LeaseCheck{Remote: "origin", Expected: oldSHA, Actual: oldSHA}},
+		{"origin", "moved", LeaseCheck{Remote: "origin", Expected: oldSHA, Actual: newSHA, Rejected: true}},
+		{"origin", "deleted", LeaseCheck{Remote: "origin", Expected: oldSHA, Rejected: true}},
+		{"origin", "unfetched", LeaseCheck{Remote: "origin", Actual: newSHA, Rejected: true}},
+		{"origin", "new", LeaseCheck{Remote: "origin"}},
+		{"offline", "same", LeaseCheck{Remote: "offline", Error: "could not reach offline"}},
+	}
+	remotes := &remoteHeads{}
+	for _, tt := range tests {
+		if got := remotes.leaseCheck(tt.remote, tt.branch); got != tt.want {
+			t.Errorf("leaseCheck(%s, %s) = %+v, want %+v", tt.remote, tt.branch, got, tt.want)
+		}
+	}
+
+	queried := 0
+	for _, call := range fake.Calls {
+		if call == "ls-remote --heads origin" {
+			queried++
+		}
+	}
+	if queried != 1 {
+		t.Errorf("origin was queried %d times, want once", queried)
+	}
+}
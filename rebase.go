@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Rebase is a rebase of a single branch onto the base branch, or onto its
+// parent for a stacked branch. Depending on Strategy the base branch is merged
+// in or fast-forwarded to instead. It runs in a
+// temporary worktree, or in the current worktree when the branch is checked
+// out there. On a conflict the rebase can be left paused so the user can
+// resolve it, then continued, skipped past or aborted.
+type Rebase struct {
+	Branch   string
+	Base     string   // what the branch is rebased onto
+	Upstream string   // commit the branch's own commits start after, when they are moved with --onto
+	Strategy Strategy // rebase, merge or ff-only
+	Dir      string   // worktree the rebase runs in
+	InPlace  bool     // rebasing the checked-out branch in the user's worktree
+
+	oldSHA  string
+	cleanup func()
+}
+
+// ConflictInfo describes a paused rebase
+type ConflictInfo struct {
+	Files   []string // files with unresolved conflicts
+	Commit  string   // short SHA of the commit being replayed (or merged in)
+	Subject string   // subject of the commit being replayed (or merged in)
+	Step    int      // position of the commit in the rebase (1-based), 0 if unknown
+	Total   int      // number of commits being replayed, 0 if unknown
+}
+
+// StartRebase prepares a rebase of a branch onto the base branch. When upstream
+// is set, only the commits after it are replayed (git rebase --onto base upstream).
+func StartRebase(branchName string, baseBranch string, upstream string, strategy Strategy) (*Rebase, error) {
+	r := &Rebase{Branch: branchName, Base: baseBranch, Upstream: upstream, Strategy: strategy, cleanup: func() {}}
+
+	if NeedsInPlaceRebase(branchName) {
+		root, err := GetWorktreeRoot()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrCheckoutFailed, err)
+		}
+		r.Dir = root
+		r.InPlace = true
+		return r, nil
+	}
+
+	if path, ok := checkedOutElsewhere(branchName); ok {
+		return nil, fmt.Errorf("%w: '%s' is checked out in worktree %s", ErrCheckoutFailed, branchName, path)
+	}
+
+	oldSHA, err := gitOutput("rev-parse", "refs/heads/"+branchName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCheckoutFailed, err)
+	}
+	dir, cleanup, err := addTempWorktree(oldSHA)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCheckoutFailed, err)
+	}
+	r.Dir = dir
+	r.oldSHA = oldSHA
+	r.cleanup = cleanup
+	return r, nil
+}
+
+// Run replays the branch onto the base branch. On a conflict it returns
+// ErrRebaseConflict and leaves the rebase paused. A merge returns
+// ErrMergeConflict instead, and a fast-forward that isn't possible returns
+// ErrNotFastForward.
+func (r *Rebase) Run() error {
+	switch r.Strategy {
+	case StrategyMerge:
+		message := fmt.Sprintf("Merge branch '%s' into %s", r.Base, r.Branch)
+		if err := gitCombined("-C", r.Dir, "merge", "--no-edit", "-m", message, r.Base); err != nil {
+			if r.InProgress() {
+				return ErrMergeConflict
+			}
+			return err
+		}
+		return nil
+	case StrategyFFOnly:
+		if err := runGit("-C", r.Dir, "merge", "--ff-only", r.Base); err != nil {
+			return fmt.Errorf("%w: '%s' has commits that '%s' doesn't", ErrNotFastForward, r.Branch, r.Base)
+		}
+		return nil
+	}
+
+	args := []string{"-C", r.Dir, "rebase", r.Base}
+	if r.Upstream != "" {
+		args = []string{"-C", r.Dir, "rebase", "--onto", r.Base, r.Upstream}
+	}
+	return r.conflictOr(gitCombined(args...))
+}
+
+// conflictOr turns the error of a rebase step into ErrRebaseConflict if it
+// left the rebase paused on a conflict, and returns any other failure (a bad
+// ref, a locked index) as it is
+func (r *Rebase) conflictOr(err error) error {
+	if err != nil && r.InProgress() {
+		return ErrRebaseConflict
+	}
+	return err
+}
+
+// InProgress reports whether the rebase is paused, waiting for the user
+func (r *Rebase) InProgress() bool {
+	return runGit("-C", r.Dir, "rev-parse", "-q", "--verify", r.conflictHead()) == nil
+}
+
+// conflictHead is the ref git points at the commit being replayed (or merged in)
+func (r *Rebase) conflictHead() string {
+	if r.Strategy == StrategyMerge {
+		return "MERGE_HEAD"
+	}
+	return "REBASE_HEAD"
+}
+
+// Conflict describes the paused rebase: the conflicted files and the commit being replayed
+func (r *Rebase) Conflict() (*ConflictInfo, error) {
+	files, err := gitOutput("-C", r.Dir, "diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ConflictInfo{}
+	if files != "" {
+		info.Files = strings.Split(files, "\n")
+	}
+	if commit, err := gitOutput("-C", r.Dir, "log", "-1", "--format=%h %s", r.conflictHead()); err == nil {
+		parts := strings.SplitN(commit, " ", 2)
+		info.Commit = parts[0]
+		if len(parts) == 2 {
+			info.Subject = parts[1]
+		}
+	}
+	if r.Strategy != StrategyMerge {
+		info.Step = r.readRebaseState("msgnum")
+		info.Total = r.readRebaseState("end")
+	}
+	return info, nil
+}
+
+// readRebaseState reads a counter from the rebase-merge state directory
+func (r *Rebase) readRebaseState(name string) int {
+	path, err := gitOutput("-C", r.Dir, "rev-parse", "--git-path", "rebase-merge/"+name)
+	if err != nil {
+		return 0
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.Dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return n
+}
+
+// Continue stages the resolved files and continues the rebase (or commits the
+// merge). It returns ErrRebaseConflict if the next commit conflicts too.
+func (r *Rebase) Continue() error {
+	info, err := r.Conflict()
+	if err != nil {
+		return err
+	}
+	for _, file := range info.Files {
+		if hasConflictMarkers(filepath.Join(r.Dir, file)) {
+			return fmt.Errorf("%s still contains conflict markers", file)
+		}
+	}
+	if len(info.Files) > 0 {
+		args := append([]string{"-C", r.Dir, "add", "--"}, info.Files...)
+		if err := gitCombined(args...); err != nil {
+			return err
+		}
+	}
+	if r.Strategy == StrategyMerge {
+		return gitCombined("-C", r.Dir, "commit", "--no-edit")
+	}
+	return r.conflictOr(gitCombined("-C", r.Dir, "-c", "core.editor=true", "rebase", "--continue"))
+}
+
+// Skip drops the commit being replayed and continues the rebase. It returns
+// ErrRebaseConflict if the next commit conflicts too.
+func (r *Rebase) Skip() error {
+	if r.Strategy == StrategyMerge {
+		return fmt.Errorf("a merge has no commit to skip, resolve or abort it")
+	}
+	return r.conflictOr(gitCombined("-C", r.Dir, "rebase", "--skip"))
+}
+
+// Abort abandons the rebase and leaves the branch where it was
+func (r *Rebase) Abort() {
+	if r.Strategy == StrategyMerge {
+		runGit("-C", r.Dir, "merge", "--abort")
+	} else {
+		runGit("-C", r.Dir, "rebase", "--abort")
+	}
+	r.cleanup()
+}
+
+// Finish moves the branch to the rebased (or merged) commit and removes the temporary worktree
+func (r *Rebase) Finish() error {
+	defer r.cleanup()
+	if r.InPlace {
+		return nil
+	}
+
+	newSHA, err := gitOutput("-C", r.Dir, "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+
+	// Only move the branch if nobody else moved it while we were rebasing
+	reason := "gitsync: rebase onto " + r.Base
+	switch r.Strategy {
+	case StrategyMerge:
+		reason = "gitsync: merge " + r.Base
+	case StrategyFFOnly:
+		reason = "gitsync: fast-forward to " + r.Base
+	}
+	return gitCombined("update-ref", "-m", reason, "refs/heads/"+r.Branch, newSHA, r.oldSHA)
+}
+
+// hasConflictMarkers reports whether a file still contains conflict markers
+func hasConflictMarkers(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "<<<<<<< ") || strings.HasPrefix(line, ">>>>>>> ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRebaseRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		command  string // the step that fails
+		paused   string // the ref a paused step leaves behind, if it pauses
+		want     error
+		wantMsg  string
+	}{
+		{"success", StrategyRebase, "", "", nil, ""},
+		{"rebase conflict", StrategyRebase, "-C /wt rebase main", "REBASE_HEAD", ErrRebaseConflict, ""},
+		{"rebase failure", StrategyRebase, "-C /wt rebase main", "", nil, "fatal: Unable to create '/wt/.git/index.lock'"},
+		{"merge conflict", StrategyMerge, "-C /wt merge --no-edit -m Merge branch 'main' into feat main", "MERGE_HEAD", ErrMergeConflict, ""},
+		{"merge failure", StrategyMerge, "-C /wt merge --no-edit -m Merge branch 'main' into feat main", "", nil, "fatal: Unable to create '/wt/.git/index.lock'"},
+		{"not a fast-forward", StrategyFFOnly, "-C /wt merge --ff-only main", "", ErrNotFastForward, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeRunner(t)
+			if tt.command != "" {
+				fake.On(tt.command, "fatal: Unable to create '/wt/.git/index.lock': File exists.", errNoRef)
+			}
+			for _, ref := range []string{"REBASE_HEAD", "MERGE_HEAD"} {
+				if ref != tt.paused {
+					fake.On("-C /wt rev-parse -q --verify "+ref, "", errNoRef)
+				}
+			}
+
+			r := &Rebase{Branch: "feat", Base: "main", Strategy: tt.strategy, Dir: "/wt"}
+			err := r.Run()
+			switch {
+			case tt.wantMsg != "":
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantMsg) || errors.Is(err, ErrRebaseConflict) || errors.Is(err, ErrMergeConflict) {
+					t.Errorf("Run() = %v, want the git error %q", err, tt.wantMsg)
+				}
+			case tt.want == nil:
+				if err != nil {
+					t.Errorf("Run() = %v", err)
+				}
+			case !errors.Is(err, tt.want):
+				t.Errorf("Run() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRebaseConflict(t *testing.T) {
+	state := t.TempDir()
+	if err := os.WriteFile(filepath.Join(state, "msgnum"), []byte("2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(state, "end"), []byte("3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := useFakeRunner(t)
+	fake.On("-C /wt diff --name-only --diff-filter=U", "a.go\ndir/b.go\n", nil)
+	fake.On("-C /wt log -1 --format=%h %s REBASE_HEAD", "abc1234 Fix the parser\n", nil)
+	fake.On("-C /wt rev-parse --git-path rebase-merge/msgnum", filepath.Join(state, "msgnum")+"\n", nil)
+	fake.On("-C /wt rev-parse --git-path rebase-merge/end", filepath.Join(state, "end")+"\n", nil)
+
+	r := &Rebase{Branch: "feat", Base: "main", Dir: "/wt"}
+	info, err := r.Conflict()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ConflictInfo{Files: []string{"a.go", "dir/b.go"}, Commit: "abc1234", Subject: "Fix the parser", Step: 2, Total: 3}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("Conflict() = %+v, want %+v", info, want)
+	}
+}
+
+func TestRebaseContinueConflictsAgain(t *testing.T) {
+	fake := useFakeRunner(t)
+	fake.On("-C /wt -c core.editor=true rebase --continue", "CONFLICT (content): Merge conflict in a", errNoRef)
+
+	r := &Rebase{Branch: "feat", Base: "main", Dir: "/wt"}
+	if err := r.Continue(); !errors.Is(err, ErrRebaseConflict) {
+		t.Errorf("Continue() = %v, want %v", err, ErrRebaseConflict)
+	}
+}
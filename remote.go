@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RemoteBranch is a branch on the push remote, as of its remote-tracking ref
+type RemoteBranch struct {
+	Name    string    `json:"name"`
+	SHA     string    `json:"sha"`
+	Subject string    `json:"subject"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"`
+	Base    string    `json:"base"`   // base branch it is checked against
+	Merged  bool      `json:"merged"` // contained in its base branch on the upstream remote
+	Local   bool      `json:"local"`  // a local branch with the same name exists
+	Gone    bool      `json:"gone"`   // already deleted on the remote, only the remote-tracking ref is left
+	Moved   bool      `json:"moved"`  // pushed to since the remote was last fetched
+}
+
+// DefaultPushRemote returns the remote branches are pushed to unless they set
+// their own: remote.pushDefault, then origin_remote
+func (c *Config) DefaultPushRemote() string {
+	if remote, err := gitOutput("config", "remote.pushDefault"); err == nil && remote != "" {
+		return remote
+	}
+	return c.OriginRemote
+}
+
+// ListRemoteBranches lists the branches on remote, most recent commit first.
+// Base branches and branches hidden by the include and exclude patterns are
+// left out. The remote is asked (git ls-remote) which branches it still has,
+// but remote-tracking refs are not fetched: deletes are leased against them.
+// If the remote can't be reached, Gone and Moved are left unset.
+func ListRemoteBranches(config *Config, remote string) ([]*RemoteBranch, error) {
+	prefix := "refs/remotes/" + remote + "/"
+	output, err := gitOutput("for-each-ref", "--sort=-committerdate",
+		"--format=%(refname)\t%(objectname)\t%(committerdate:unix)\t%(authorname)\t%(subject)", prefix)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := config.BranchFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	protected := map[string]bool{config.BaseBranch: true}
+	for _, rule := range config.Bases {
+		protected[rule.Base] = true
+	}
+	local := map[string]bool{}
+	if names, err := gitOutput("for-each-ref", "--format=%(refname:short)", "refs/heads"); err == nil {
+		for _, name := range strings.Split(names, "\n") {
+			local[name] = true
+		}
+	}
+	heads, headsErr := (&remoteHeads{}).list(remote)
+
+	var branches []*RemoteBranch
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(line, "\t", 5)
+		if len(parts) != 5 {
+			continue
+		}
+		name := strings.TrimPrefix(parts[0], prefix)
+		if name == "HEAD" || protected[name] {
+			continue
+		}
+		if filter.Hidden(name) {
+			continue
+		}
+
+		b := &RemoteBranch{Name: name, SHA: parts[1], Author: parts[3], Subject: parts[4], Local: local[name]}
+		if unix, err := strconv.ParseInt(parts[2], 10, 64); err == nil {
+			b.Date = time.Unix(unix, 0)
+		}
+		if headsErr == nil {
+			sha, ok := heads["refs/heads/"+name]
+			b.Gone = !ok
+			b.Moved = ok && sha != b.SHA
+		}
+		b.Base, _ = config.ResolveBase(name)
+		branches = append(branches, b)
+	}
+
+	// One 'for-each-ref --merged' per base instead of a merge-base per branch
+	merged := map[string]map[string]bool{}
+	for _, b := range branches {
+		if _, ok := merged[b.Base]; !ok {
+			merged[b.Base] = map[string]bool{}
+			output, err := gitOutput("for-each-ref", "--format=%(refname)", "--merged", config.UpstreamRemote+"/"+b.Base, prefix)
+			if err == nil {
+				for _, ref := range strings.Split(output, "\n") {
+					merged[b.Base][strings.TrimPrefix(ref, prefix)] = true
+				}
+			}
+		}
+		b.Merged = merged[b.Base][b.Name]
+	}
+	return branches, nil
+}
+
+// DeleteRemoteBranches deletes branches on remote with a single push, leased
+// against their remote-tracking refs so commits pushed since the last fetch are
+// never deleted. Branches already gone from the remote only lose their
+// remote-tracking ref. Every branch is recorded in backup first and the deleted
+// ones in the trash. It returns the names that were deleted, even on error.
+func DeleteRemoteBranches(remote string, branches []*RemoteBranch, backup *Backup) ([]string, error) {
+	var deleted, names []string
+	for _, b := range branches {
+		if err := backup.RecordRemote(remote, b.Name); err != nil {
+			return nil, err
+		}
+		if b.Gone {
+			if err := gitCombined("update-ref", "-d", "refs/remotes/"+remote+"/"+b.Name, b.SHA); err != nil {
+				return deleted, err
+			}
+			deleted = append(deleted, b.Name)
+			continue
+		}
+		names = append(names, b.Name)
+	}
+	if len(names) == 0 {
+		return deleted, nil
+	}
+
+	pushErr := gitCombined(append([]string{"push", "--force-with-lease", remote, "--delete"}, names...)...)
+
+	// The push deletes what it can: a deleted branch's remote-tracking ref is gone
+	var failed []string
+	for _, b := range branches {
+		if b.Gone {
+			continue
+		}
+		if runGit("rev-parse", "-q", "--verify", "refs/remotes/"+remote+"/"+b.Name) == nil {
+			failed = append(failed, b.Name)
+			continue
+		}
+		deleted = append(deleted, b.Name)
+		entry := TrashEntry{Name: b.Name, SHA: b.SHA, Remote: remote, RemoteSHA: b.SHA, RemoteOnly: true, DeletedAt: time.Now()}
+		if err := RecordTrash(entry); err != nil {
+			return deleted, fmt.Errorf("deleted, but could not record %s in the trash: %w", b.Name, err)
+		}
+	}
+	if len(failed) > 0 {
+		return deleted, fmt.Errorf("failed to delete %s on %s: %w", strings.Join(failed, ", "), remote, pushErr)
+	}
+	return deleted, nil
+}
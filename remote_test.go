@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDeleteRemoteBranches(t *testing.T) {
+	tests := []struct {
+		name     string
+		rejected string // the branch whose lease fails: its remote-tracking ref stays
+		deleted  []string
+		wantErr  string
+	}{
+		{"all deleted", "", []string{"gone", "a", "b"}, ""},
+		{"lease rejected", "b", []string{"gone", "a"}, "failed to delete b on origin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeRunner(t)
+			useTrash(t, fake)
+			for _, name := range []string{"a", "b"} {
+				if name != tt.rejected {
+					fake.On("rev-parse -q --verify refs/remotes/origin/"+name, "", errNoRef)
+				}
+			}
+			if tt.rejected != "" {
+				fake.On("push --force-with-lease origin --delete a b", "! [rejected] "+tt.rejected+" (stale info)", errNoRef)
+			}
+
+			branches := []*RemoteBranch{{Name: "gone", SHA: oldSHA, Gone: true}, {Name: "a", SHA: oldSHA}, {Name: "b", SHA: newSHA}}
+			deleted, err := DeleteRemoteBranches("origin", branches, nil)
+			if !reflect.DeepEqual(deleted, tt.deleted) {
+				t.Errorf("deleted = %q, want %q", deleted, tt.deleted)
+			}
+			if (tt.wantErr == "") != (err == nil) || (err != nil && !strings.HasPrefix(err.Error(), tt.wantErr)) {
+				t.Errorf("DeleteRemoteBranches() = %v, want %q", err, tt.wantErr)
+			}
+
+			pushes := 0
+			for _, call := range fake.Calls {
+				if strings.HasPrefix(call, "push") {
+					pushes++
+				}
+			}
+			if pushes != 1 {
+				t.Errorf("pushed %d times, want one leased push: %q", pushes, fake.Calls)
+			}
+			// A branch gone from the remote only loses its remote-tracking ref
+			checkCalls(t, fake, []string{"update-ref -d refs/remotes/origin/gone " + oldSHA}, nil)
+
+			trash, err := ListTrash()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var trashed []string
+			for _, e := range trash {
+				if !e.RemoteOnly || e.Remote != "origin" || e.RemoteSHA != e.SHA {
+					t.Errorf("trash entry = %+v, want a remote-only entry for origin", e)
+				}
+				trashed = append(trashed, e.Name)
+			}
+			if want := tt.deleted[1:]; len(trashed) != len(want) {
+				t.Errorf("trash = %q, want %q", trashed, want)
+			}
+		})
+	}
+}
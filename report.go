@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// reportSchemaVersion is bumped whenever a field is renamed or removed from the JSON output.
+// Adding fields does not change the version.
+const reportSchemaVersion = 1
+
+// Error classes reported for failed branches
+const (
+	errorClassFetch    = "fetch"    // fetching the upstream base branch failed
+	errorClassBase     = "base"     // the local base branch could not be updated
+	errorClassCheckout = "checkout" // the branch could not be checked out
+	errorClassConflict = "conflict" // the rebase or merge hit a conflict and was aborted
+	errorClassDiverged = "diverged" // an ff-only branch has commits of its own
+	errorClassPush     = "push"     // pushing the branch failed (e.g. lease rejected)
+	errorClassStack    = "stack"    // the branch is stacked on a branch that failed
+	errorClassUnknown  = "unknown"
+)
+
+// RepoReport describes the repository a command ran against
+type RepoReport struct {
+	SchemaVersion  int    `json:"schema_version"`
+	BaseBranch     string `json:"base_branch"`
+	UpstreamRemote string `json:"upstream_remote"`
+	OriginRemote   string `json:"origin_remote"`
+}
+
+// BranchReport is a branch as reported by `gitsync list --json`
+type BranchReport struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Behind      int    `json:"behind"`
+	Ahead       int    `json:"ahead"`
+	LastCommit  string `json:"last_commit"`
+	Status      string `json:"status"`
+	Parent      string `json:"parent,omitempty"` // branch this one is stacked on
+	Strategy    string `json:"strategy"`         // rebase, merge, ff-only or skip
+	Base        string `json:"base"`             // base branch the counts are against
+
+	PushRemote   string `json:"push_remote"`
+	Unpushed     int    `json:"unpushed"`      // commits not on the branch on push_remote
+	RemoteAhead  int    `json:"remote_ahead"`  // commits on the branch on push_remote that the local branch doesn't have
+	RemoteMoved  bool   `json:"remote_moved"`  // push_remote has commits that haven't been fetched; remote_ahead is a lower bound
+	OriginStatus string `json:"origin_status"` // in-sync, unpushed, remote-ahead, diverged-from-origin or local-only
+}
+
+// ListReport is the output of `gitsync list --json`
+type ListReport struct {
+	RepoReport
+	Branches []BranchReport `json:"branches"`
+	Hidden   []HiddenBranch `json:"hidden,omitempty"` // only with --show-hidden
+}
+
+// RemoteReport is the output of `gitsync remote --json`
+type RemoteReport struct {
+	RepoReport
+	Remote   string          `json:"remote"`
+	Branches []*RemoteBranch `json:"branches"`
+}
+
+// SyncResult is the outcome of syncing a single branch
+type SyncResult struct {
+	Name       string `json:"name"`
+	Strategy   string `json:"strategy"`
+	Outcome    string `json:"outcome"` // "updated", "skipped" or "failed"
+	Error      string `json:"error,omitempty"`
+	ErrorClass string `json:"error_class,omitempty"`
+}
+
+// SyncReport is the output of `gitsync sync --json`
+type SyncReport struct {
+	RepoReport
+	Updated int          `json:"updated"`
+	Skipped int          `json:"skipped"`
+	Failed  int          `json:"failed"`
+	Results []SyncResult `json:"results"`
+	Backup  string       `json:"backup,omitempty"` // pass to 'gitsync undo' to revert the run
+}
+
+// PlanReport is the output of `gitsync sync --dry-run --json`
+type PlanReport struct {
+	RepoReport
+	*SyncPlan
+	Failures int `json:"failures"` // steps predicted to fail
+}
+
+// ErrorReport is printed instead of a report when a command fails fatally
+type ErrorReport struct {
+	SchemaVersion int    `json:"schema_version"`
+	Error         string `json:"error"`
+	ErrorClass    string `json:"error_class"`
+}
+
+// newRepoReport creates the repository part of a report
+func newRepoReport(config *Config) RepoReport {
+	return RepoReport{
+		SchemaVersion:  reportSchemaVersion,
+		BaseBranch:     config.BaseBranch,
+		UpstreamRemote: config.UpstreamRemote,
+		OriginRemote:   config.OriginRemote,
+	}
+}
+
+// newBranchReport converts a Branch to its JSON form
+func newBranchReport(b *Branch) BranchReport {
+	return BranchReport{
+		Name:        b.Name,
+		Description: b.Description,
+		Behind:      b.Behind,
+		Ahead:       b.Ahead,
+		LastCommit:  b.LastCommit,
+		Status:      b.Status,
+		Parent:      b.Parent,
+		Strategy:    string(b.Strategy),
+		Base:        b.Base,
+
+		PushRemote:   b.PushRemote,
+		Unpushed:     b.Unpushed,
+		RemoteAhead:  b.RemoteAhead,
+		RemoteMoved:  b.RemoteMoved,
+		OriginStatus: b.OriginStatus,
+	}
+}
+
+// newSyncResult creates the result for a synced branch; err is nil on success
+func newSyncResult(b *Branch, err error) SyncResult {
+	result := SyncResult{Name: b.Name, Strategy: string(b.Strategy), Outcome: "updated"}
+	switch {
+	case err != nil:
+		result.Outcome = "failed"
+		result.Error = err.Error()
+		result.ErrorClass = classifyError(err)
+	case b.Strategy == StrategySkip:
+		result.Outcome = "skipped"
+	}
+	return result
+}
+
+// classifyError maps a sync error to its error class
+func classifyError(err error) string {
+	switch {
+	case errors.Is(err, ErrFetchFailed):
+		return errorClassFetch
+	case errors.Is(err, ErrBaseUpdateFailed):
+		return errorClassBase
+	case errors.Is(err, ErrCheckoutFailed):
+		return errorClassCheckout
+	case errors.Is(err, ErrRebaseConflict), errors.Is(err, ErrMergeConflict):
+		return errorClassConflict
+	case errors.Is(err, ErrNotFastForward):
+		return errorClassDiverged
+	case errors.Is(err, ErrPushFailed):
+		return errorClassPush
+	case errors.Is(err, ErrParentFailed):
+		return errorClassStack
+	}
+	return errorClassUnknown
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// fatalJSON prints an error report and returns the fatal exit code
+func fatalJSON(err error) int {
+	printJSON(ErrorReport{SchemaVersion: reportSchemaVersion, Error: err.Error(), ErrorClass: classifyError(err)})
+	return exitFatal
+}
+
+// jsonOrFatal reports a fatal error as JSON or as text
+func jsonOrFatal(jsonOut bool, err error) int {
+	if jsonOut {
+		return fatalJSON(err)
+	}
+	return fatalf("%v", err)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrStashNotRestored is returned when stashed changes could not be re-applied after a run
+var ErrStashNotRestored = errors.New("stashed changes could not be restored")
+
+// RestorePoint records where the user was before a sync or delete run,
+// so the checkout and any stashed changes can be put back afterwards
+type RestorePoint struct {
+	Branch   string // checked-out branch, empty when HEAD was detached
+	Head     string // HEAD commit, used to return to a detached HEAD
+	Stashed  bool   // changes were stashed for this run
+	stashSHA string
+	restored bool
+}
+
+// activeRestore is the restore point of the run in progress, if any.
+// main restores it on exit so a crash never leaves the user stranded.
+var activeRestore *RestorePoint
+
+// CaptureRestorePoint records the current branch (or detached HEAD) and makes it the active restore point
+func CaptureRestorePoint() *RestorePoint {
+	r := &RestorePoint{}
+	r.Branch, _ = GetCurrentBranch()
+	r.Head, _ = gitOutput("rev-parse", "HEAD")
+	activeRestore = r
+	return r
+}
+
+// Stash stashes uncommitted changes; they are popped again by Restore
+func (r *RestorePoint) Stash() error {
+	if err := StashChanges(); err != nil {
+		return err
+	}
+	r.Stashed = true
+	r.stashSHA, _ = gitOutput("rev-parse", "refs/stash")
+	return nil
+}
+
+// Restore checks out the original branch (or detached HEAD) and then pops the
+// stash, if one was made. It is safe to call more than once.
+func (r *RestorePoint) Restore() error {
+	if r == nil || r.restored {
+		return nil
+	}
+	r.restored = true
+	if activeRestore == r {
+		activeRestore = nil
+	}
+
+	// Go back first, so the stash is never applied to the wrong branch
+	if err := r.checkoutOriginal(); err != nil {
+		if r.Stashed {
+			return fmt.Errorf("could not return to %s (%v); your changes are still in the stash", r.describe(), err)
+		}
+		return fmt.Errorf("could not return to %s: %w", r.describe(), err)
+	}
+
+	if !r.Stashed {
+		return nil
+	}
+	ref, err := r.stashRef()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrStashNotRestored, err)
+	}
+	if err := gitCombined("stash", "pop", ref); err != nil {
+		if conflicted, _ := gitOutput("diff", "--name-only", "--diff-filter=U"); conflicted != "" {
+			return fmt.Errorf("%w cleanly on %s: conflicts in %s. Resolve them, then run 'git stash drop %s'", ErrStashNotRestored, r.describe(), strings.ReplaceAll(conflicted, "\n", ", "), ref)
+		}
+		return fmt.Errorf("%w on %s, they are still in the stash (%s): %v", ErrStashNotRestored, r.describe(), ref, err)
+	}
+	return nil
+}
+
+// checkoutOriginal returns to the original branch or detached HEAD if we are no longer on it
+func (r *RestorePoint) checkoutOriginal() error {
+	current, _ := GetCurrentBranch()
+	if r.Branch != "" {
+		if current == r.Branch {
+			return nil
+		}
+		return CheckoutBranch(r.Branch)
+	}
+
+	head, _ := gitOutput("rev-parse", "HEAD")
+	if current == "" && head == r.Head {
+		return nil
+	}
+	return gitCombined("checkout", "--detach", r.Head)
+}
+
+// stashRef finds our stash entry, even if other stashes were pushed on top of it
+func (r *RestorePoint) stashRef() (string, error) {
+	output, err := gitOutput("stash", "list", "--format=%H")
+	if err != nil {
+		return "", err
+	}
+	for i, sha := range strings.Split(output, "\n") {
+		if sha == r.stashSHA {
+			return fmt.Sprintf("stash@{%d}", i), nil
+		}
+	}
+	return "", fmt.Errorf("stash %s not found", r.stashSHA)
+}
+
+// describe names the original position for messages
+func (r *RestorePoint) describe() string {
+	if r.Branch != "" {
+		return "'" + r.Branch + "'"
+	}
+	if len(r.Head) > 7 {
+		return "detached HEAD " + r.Head[:7]
+	}
+	return "detached HEAD"
+}
+
+// RestoreActive restores the active restore point, if a run is still in progress
+func RestoreActive() error {
+	return activeRestore.Restore()
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// GitRunner runs git commands. Every git invocation in gitsync goes through
+// the package-level runner so it can be swapped out, traced or scripted.
+type GitRunner interface {
+	// Output runs git with the given args and returns its stdout
+	Output(args ...string) (string, error)
+	// CombinedOutput runs git with the given args and returns stdout and stderr together
+	CombinedOutput(args ...string) (string, error)
+}
+
+// runner is the GitRunner used by all git operations
+var runner GitRunner = ExecRunner{}
+
+// SetGitRunner replaces the runner used by all git operations and returns the previous one
+func SetGitRunner(r GitRunner) GitRunner {
+	prev := runner
+	runner = r
+	return prev
+}
+
+// runGit runs a git command and discards its output
+func runGit(args ...string) error {
+	_, err := runner.Output(args...)
+	return err
+}
+
+// gitOutput runs a git command and returns its trimmed stdout
+func gitOutput(args ...string) (string, error) {
+	output, err := runner.Output(args...)
+	return strings.TrimSpace(output), err
+}
+
+// gitLines runs a git command and returns the lines of its stdout, keeping
+// their indentation, or nil if there are none
+func gitLines(args ...string) ([]string, error) {
+	output, err := runner.Output(args...)
+	output = strings.TrimRight(output, "\n")
+	if err != nil || output == "" {
+		return nil, err
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+// gitCombined runs a git command and turns its combined output into the error on failure
+func gitCombined(args ...string) error {
+	output, err := runner.CombinedOutput(args...)
+	if err != nil {
+		if msg := strings.TrimSpace(output); msg != "" {
+			return fmt.Errorf("%s", msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// ExecRunner runs git as a subprocess
+type ExecRunner struct{}
+
+// Output runs git and returns its stdout
+func (ExecRunner) Output(args ...string) (string, error) {
+	output, err := exec.Command("git", args...).Output()
+	return string(output), err
+}
+
+// CombinedOutput runs git and returns its stdout and stderr together
+func (ExecRunner) CombinedOutput(args ...string) (string, error) {
+	output, err := exec.Command("git", args...).CombinedOutput()
+	return string(output), err
+}
+
+// FakeResponse is a scripted result for a git command
+type FakeResponse struct {
+	Output string
+	Err    error
+}
+
+// FakeRunner is a scriptable GitRunner for tests. Responses are keyed by the
+// space-joined git args (e.g. "rebase main"). Commands without a scripted
+// response succeed with empty output. Every call is recorded in Calls.
+type FakeRunner struct {
+	mu        sync.Mutex
+	Responses map[string][]FakeResponse
+	Calls     []string
+}
+
+// NewFakeRunner creates an empty FakeRunner
+func NewFakeRunner() *FakeRunner {
+	return &FakeRunner{Responses: map[string][]FakeResponse{}}
+}
+
+// On queues a response for a git command. Queued responses are consumed in
+// order; the last one is repeated once the queue is exhausted.
+func (f *FakeRunner) On(command string, output string, err error) *FakeRunner {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Responses[command] = append(f.Responses[command], FakeResponse{Output: output, Err: err})
+	return f
+}
+
+// Output returns the scripted response for the command
+func (f *FakeRunner) Output(args ...string) (string, error) {
+	return f.respond(args)
+}
+
+// CombinedOutput returns the scripted response for the command
+func (f *FakeRunner) CombinedOutput(args ...string) (string, error) {
+	return f.respond(args)
+}
+
+// Called reports whether a command was run
+func (f *FakeRunner) Called(command string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.Calls {
+		if c == command {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FakeRunner) respond(args []string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	command := strings.Join(args, " ")
+	f.Calls = append(f.Calls, command)
+
+	queue := f.Responses[command]
+	if len(queue) == 0 {
+		return "", nil
+	}
+	resp := queue[0]
+	if len(queue) > 1 {
+		f.Responses[command] = queue[1:]
+	}
+	return resp.Output, resp.Err
+}
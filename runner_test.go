@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// errNoRef is what a scripted git command fails with, like git on a missing ref
+var errNoRef = errors.New("exit status 128")
+
+// useFakeRunner routes git calls to a FakeRunner for the rest of the test
+func useFakeRunner(t *testing.T) *FakeRunner {
+	t.Helper()
+	fake := NewFakeRunner()
+	prev := SetGitRunner(fake)
+	t.Cleanup(func() { SetGitRunner(prev) })
+	return fake
+}
+
+func TestFakeRunner(t *testing.T) {
+	fake := useFakeRunner(t)
+	failed := errors.New("exit status 1")
+	fake.On("rev-parse HEAD", "abc\n", nil).On("rev-parse HEAD", "def\n", nil)
+	fake.On("push origin main", "", failed)
+
+	tests := []struct {
+		args   []string
+		output string
+		err    error
+	}{
+		{[]string{"rev-parse", "HEAD"}, "abc", nil},
+		{[]string{"rev-parse", "HEAD"}, "def", nil},
+		{[]string{"rev-parse", "HEAD"}, "def", nil}, // the last response repeats
+		{[]string{"push", "origin", "main"}, "", failed},
+		{[]string{"status"}, "", nil}, // unscripted commands succeed
+	}
+	for _, tt := range tests {
+		output, err := gitOutput(tt.args...)
+		if output != tt.output || err != tt.err {
+			t.Errorf("gitOutput(%v) = %q, %v, want %q, %v", tt.args, output, err, tt.output, tt.err)
+		}
+	}
+	if !fake.Called("status") || fake.Called("fetch") {
+		t.Errorf("Calls = %v", fake.Calls)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrParentFailed is returned for a stacked branch whose parent could not be synced
+var ErrParentFailed = errors.New("parent branch failed")
+
+// DetectStacks finds branches built on top of other local branches and
+// returns the branches in tree order: every parent is followed by its children.
+// A branch's parent is the closest branch it contains that has commits of its
+// own (not yet in the upstream base branch).
+func DetectStacks(branches []*Branch) []*Branch {
+	byName := map[string]*Branch{}
+	for _, b := range branches {
+		byName[b.Name] = b
+	}
+
+	tips := map[string]string{}
+	if output, err := gitOutput("for-each-ref", "--format=%(objectname) %(refname:short)", "refs/heads/"); err == nil {
+		for _, line := range strings.Split(output, "\n") {
+			if sha, name, ok := strings.Cut(line, " "); ok {
+				tips[name] = sha
+			}
+		}
+	}
+
+	for _, a := range branches {
+		if a.Ahead == 0 {
+			continue
+		}
+		output, err := gitOutput("for-each-ref", "--contains", "refs/heads/"+a.Name, "--format=%(refname:short)", "refs/heads/")
+		if err != nil {
+			continue
+		}
+		for _, name := range strings.Split(output, "\n") {
+			child, ok := byName[name]
+			if !ok || child == a || tips[name] == tips[a.Name] {
+				continue
+			}
+			// The closest parent is the one furthest ahead of the base branch
+			if parent, ok := byName[child.Parent]; !ok || parent.Ahead < a.Ahead {
+				child.Parent = a.Name
+				child.ParentSHA = tips[a.Name]
+			}
+		}
+	}
+
+	children := map[string][]*Branch{}
+	var roots []*Branch
+	for _, b := range branches {
+		if b.Parent == "" {
+			roots = append(roots, b)
+		} else {
+			children[b.Parent] = append(children[b.Parent], b)
+		}
+	}
+
+	var ordered []*Branch
+	var walk func(b *Branch, depth int, stack string)
+	walk = func(b *Branch, depth int, stack string) {
+		b.Depth = depth
+		b.Stack = stack
+		ordered = append(ordered, b)
+		for _, child := range children[b.Name] {
+			walk(child, depth+1, stack)
+		}
+	}
+	for _, root := range roots {
+		stack := ""
+		if len(children[root.Name]) > 0 {
+			stack = root.Name
+		}
+		walk(root, 0, stack)
+	}
+	return ordered
+}
+
+// ExpandStacks returns the branches of every stack that has a branch in names,
+// so a stack is always synced as a whole. The result is in tree order.
+func ExpandStacks(branches []*Branch, names []string) []*Branch {
+	wanted := map[string]bool{}
+	stacks := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+	for _, b := range branches {
+		if wanted[b.Name] && b.Stack != "" {
+			stacks[b.Stack] = true
+		}
+	}
+
+	var expanded []*Branch
+	for _, b := range branches {
+		if wanted[b.Name] || stacks[b.Stack] {
+			expanded = append(expanded, b)
+		}
+	}
+	return expanded
+}
+
+// EndsStack reports whether the branch at index i is the last one of its stack in branches
+func EndsStack(branches []*Branch, i int) bool {
+	b := branches[i]
+	return b.Stack != "" && (i == len(branches)-1 || branches[i+1].Stack != b.Stack)
+}
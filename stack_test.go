@@ -0,0 +1,105 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// stackBranches returns test branches by name, in the order given
+func stackBranches(names ...string) []*Branch {
+	var branches []*Branch
+	for _, name := range names {
+		branches = append(branches, &Branch{Name: name})
+	}
+	return branches
+}
+
+// branchNames returns the names of branches
+func branchNames(branches []*Branch) []string {
+	var names []string
+	for _, b := range branches {
+		names = append(names, b.Name)
+	}
+	return names
+}
+
+func TestDetectStacks(t *testing.T) {
+	fake := useFakeRunner(t)
+	fake.On("for-each-ref --format=%(objectname) %(refname:short) refs/heads/", "1 c\n2 d\n3 b\n4 a\n4 a-copy\n5 main", nil)
+	contains := map[string]string{
+		"a": "a\na-copy\nb\nc", // a-copy points at the same commit: not stacked on a
+		"b": "b\nc",
+		"c": "c",
+		"d": "d",
+	}
+	for name, output := range contains {
+		fake.On("for-each-ref --contains refs/heads/"+name+" --format=%(refname:short) refs/heads/", output, nil)
+	}
+
+	// Listed children first, to check they are put after their parents
+	branches := stackBranches("c", "d", "b", "a", "a-copy", "main")
+	ahead := map[string]int{"a": 1, "a-copy": 1, "b": 2, "c": 3, "d": 1}
+	for _, b := range branches {
+		b.Ahead = ahead[b.Name]
+	}
+
+	ordered := DetectStacks(branches)
+
+	type stacked struct {
+		Name, Parent, ParentSHA, Stack string
+		Depth                          int
+	}
+	var got []stacked
+	for _, b := range ordered {
+		got = append(got, stacked{b.Name, b.Parent, b.ParentSHA, b.Stack, b.Depth})
+	}
+	want := []stacked{
+		{"d", "", "", "", 0},
+		{"a", "", "", "a", 0},
+		{"b", "a", "4", "a", 1},
+		{"c", "b", "3", "a", 2},
+		{"a-copy", "", "", "", 0},
+		{"main", "", "", "", 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DetectStacks =\n%+v\nwant\n%+v", got, want)
+	}
+	if fake.Called("for-each-ref --contains refs/heads/main --format=%(refname:short) refs/heads/") {
+		t.Error("a branch without commits of its own was looked up as a parent")
+	}
+}
+
+func TestExpandStacks(t *testing.T) {
+	branches := stackBranches("a", "b", "c", "d", "e")
+	for _, b := range branches[:3] {
+		b.Stack = "a"
+	}
+
+	tests := []struct {
+		names []string
+		want  []string
+	}{
+		{nil, nil},
+		{[]string{"d"}, []string{"d"}},
+		{[]string{"b"}, []string{"a", "b", "c"}},
+		{[]string{"e", "c"}, []string{"a", "b", "c", "e"}},
+	}
+	for _, tt := range tests {
+		if got := branchNames(ExpandStacks(branches, tt.names)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExpandStacks(%v) = %v, want %v", tt.names, got, tt.want)
+		}
+	}
+}
+
+func TestEndsStack(t *testing.T) {
+	branches := stackBranches("a", "b", "c", "d", "e", "f")
+	for i, stack := range []string{"a", "a", "", "d", "e", "e"} {
+		branches[i].Stack = stack
+	}
+	want := []bool{false, true, false, true, false, true}
+	for i := range branches {
+		if got := EndsStack(branches, i); got != want[i] {
+			t.Errorf("EndsStack(%s) = %v, want %v", branches[i].Name, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Strategy is how a branch is brought up to date with its base branch
+type Strategy string
+
+const (
+	StrategyRebase Strategy = "rebase"  // replay the branch's commits onto the base branch and force-push (with lease)
+	StrategyMerge  Strategy = "merge"   // merge the base branch into the branch; a plain push, never forced
+	StrategyFFOnly Strategy = "ff-only" // fast-forward the branch to the base branch; fails if it has commits of its own
+	StrategySkip   Strategy = "skip"    // leave the branch alone
+)
+
+// Strategies lists every strategy, in the order the TUI cycles through them
+var Strategies = []Strategy{StrategyRebase, StrategyMerge, StrategyFFOnly, StrategySkip}
+
+// StrategyRule assigns a strategy to the branches matching a pattern
+type StrategyRule struct {
+	Pattern  string   `yaml:"pattern"`
+	Strategy Strategy `yaml:"strategy"`
+}
+
+// ParseStrategy validates a strategy name
+func ParseStrategy(name string) (Strategy, error) {
+	for _, s := range Strategies {
+		if string(s) == name {
+			return s, nil
+		}
+	}
+	var names []string
+	for _, s := range Strategies {
+		names = append(names, string(s))
+	}
+	return "", fmt.Errorf("unknown strategy %q (use %s)", name, strings.Join(names, ", "))
+}
+
+// ForcePush reports whether the strategy rewrites the branch, so it has to be force-pushed
+func (s Strategy) ForcePush() bool {
+	return s == StrategyRebase
+}
+
+// Verb describes what the strategy did to a branch, e.g. "merged"
+func (s Strategy) Verb() string {
+	switch s {
+	case StrategyMerge:
+		return "merged"
+	case StrategyFFOnly:
+		return "fast-forwarded"
+	case StrategySkip:
+		return "skipped"
+	}
+	return "rebased"
+}
+
+// ResolveStrategy returns the strategy for a branch and where it came from:
+// branch.<name>.gitsyncStrategy, then the last matching rule in strategies,
+// then rebase. An invalid setting resolves to skip, so a misspelled "merge"
+// never gets a shared branch rebased and force-pushed.
+func (c *Config) ResolveStrategy(branchName string) (Strategy, string) {
+	if name := GetBranchStrategy(branchName); name != "" {
+		s, err := ParseStrategy(name)
+		if err != nil {
+			return StrategySkip, fmt.Sprintf("branch.%s.gitsyncStrategy: %v", branchName, err)
+		}
+		return s, "branch." + branchName + ".gitsyncStrategy"
+	}
+
+	var patterns []string
+	for _, rule := range c.Strategies {
+		patterns = append(patterns, rule.Pattern)
+	}
+	i, err := LastMatch(branchName, patterns)
+	if err != nil {
+		return StrategySkip, fmt.Sprintf("strategies: %v", err)
+	}
+	if i < 0 {
+		return StrategyRebase, "default"
+	}
+	rule := c.Strategies[i]
+	s, err := ParseStrategy(string(rule.Strategy))
+	if err != nil {
+		return StrategySkip, fmt.Sprintf("strategies: %q: %v", rule.Pattern, err)
+	}
+	return s, fmt.Sprintf("strategies: %q", rule.Pattern)
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestResolveStrategy(t *testing.T) {
+	fake := useFakeRunner(t)
+	fake.On("config branch.pinned.gitsyncStrategy", "ff-only\n", nil)
+	fake.On("config branch.typo.gitsyncStrategy", "merj\n", nil)
+
+	config := &Config{Strategies: []StrategyRule{
+		{Pattern: "shared/**", Strategy: StrategyMerge},
+		{Pattern: "shared/solo-*", Strategy: StrategyRebase},
+		{Pattern: "release/*", Strategy: StrategyFFOnly},
+		{Pattern: "old/*", Strategy: "merj"},
+	}}
+	tests := []struct {
+		branch   string
+		strategy Strategy
+		source   string
+	}{
+		{"feature/a", StrategyRebase, "default"},
+		{"shared/team/a", StrategyMerge, `strategies: "shared/**"`},
+		{"shared/solo-1", StrategyRebase, `strategies: "shared/solo-*"`},
+		{"release/1.2", StrategyFFOnly, `strategies: "release/*"`},
+		{"pinned", StrategyFFOnly, "branch.pinned.gitsyncStrategy"},
+		// Misspelled strategies never fall back to a force-pushing rebase
+		{"typo", StrategySkip, `branch.typo.gitsyncStrategy: unknown strategy "merj" (use rebase, merge, ff-only, skip)`},
+		{"old/a", StrategySkip, `strategies: "old/*": unknown strategy "merj" (use rebase, merge, ff-only, skip)`},
+	}
+	for _, tt := range tests {
+		strategy, source := config.ResolveStrategy(tt.branch)
+		if strategy != tt.strategy || source != tt.source {
+			t.Errorf("ResolveStrategy(%q) = %s, %q, want %s, %q", tt.branch, strategy, source, tt.strategy, tt.source)
+		}
+	}
+}
+
+func TestResolveStrategyInvalidPattern(t *testing.T) {
+	useFakeRunner(t)
+	config := &Config{Strategies: []StrategyRule{{Pattern: "re:(", Strategy: StrategyMerge}}}
+	if strategy, _ := config.ResolveStrategy("feature/a"); strategy != StrategySkip {
+		t.Errorf("ResolveStrategy with an invalid pattern = %s, want skip", strategy)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PrepareBase fetches an upstream base branch and updates the local base branch from it.
+// It runs once per base branch and sync, before any branch on it is rebased. A base
+// branch that doesn't exist locally is only fetched. The old base branch is recorded in backup.
+func PrepareBase(config *Config, base string, backup *Backup) error {
+	if err := FetchUpstream(config.UpstreamRemote, base); err != nil {
+		return fmt.Errorf("%w for '%s/%s'", ErrFetchFailed, config.UpstreamRemote, base)
+	}
+	if !hasLocalBranch(base) {
+		return nil
+	}
+	pushRemote := config.PushRemote(base)
+	if err := backupRefs(backup, base, pushRemote); err != nil {
+		return fmt.Errorf("%w: %w", ErrBaseUpdateFailed, err)
+	}
+	if err := UpdateBaseBranch(base, config.UpstreamRemote, pushRemote); err != nil {
+		return fmt.Errorf("%w: %w", ErrBaseUpdateFailed, err)
+	}
+	return nil
+}
+
+// syncOnto returns what branches based on base are synced onto: the local base
+// branch, or the upstream one if there is no local branch
+func syncOnto(config *Config, base string) string {
+	if hasLocalBranch(base) {
+		return base
+	}
+	return config.UpstreamRemote + "/" + base
+}
+
+// hasLocalBranch reports whether a local branch exists
+func hasLocalBranch(branchName string) bool {
+	return runGit("rev-parse", "--verify", "-q", "refs/heads/"+branchName) == nil
+}
+
+// SyncBranch rebases a branch onto the base branch and pushes it, or merges or
+// fast-forwards it according to its strategy. The rebase runs in a
+// temporary worktree unless the branch is the one currently checked out.
+// Conflicting rebases are aborted. Branches set to skip are left alone.
+func SyncBranch(b *Branch, config *Config, backup *Backup) error {
+	if b.Strategy == StrategySkip {
+		return nil
+	}
+	r, err := StartSync(b, config, backup)
+	if err != nil {
+		if r != nil {
+			r.Abort()
+		}
+		return err
+	}
+	return FinishSync(r, config)
+}
+
+// RebaseBranch rebases a branch like SyncBranch but doesn't push it. Stacked
+// branches are rebased one by one and then pushed together with PushStack.
+func RebaseBranch(b *Branch, config *Config, backup *Backup) error {
+	if b.Strategy == StrategySkip {
+		return nil
+	}
+	r, err := StartSync(b, config, backup)
+	if err != nil {
+		if r != nil {
+			r.Abort()
+		}
+		return err
+	}
+	return r.Finish()
+}
+
+// StartSync starts rebasing a branch onto its base branch, or a stacked branch
+// onto its (already rebased) parent, using the branch's strategy. On a conflict
+// it returns the paused rebase along with ErrRebaseConflict (ErrMergeConflict
+// for a merge), so the caller can resolve it (and then call FinishSync) or
+// abort it. The branch and its push remote are recorded in backup before
+// anything changes.
+func StartSync(b *Branch, config *Config, backup *Backup) (*Rebase, error) {
+	if err := backupRefs(backup, b.Name, config.PushRemote(b.Name)); err != nil {
+		return nil, err
+	}
+	base := b.Base
+	if base == "" {
+		base = config.BaseBranch
+	}
+	onto, upstream := syncOnto(config, base), ""
+	if b.Parent != "" {
+		onto, upstream = b.Parent, b.ParentSHA
+	}
+	strategy := b.Strategy
+	if strategy == "" {
+		strategy = StrategyRebase
+	}
+	r, err := StartRebase(b.Name, onto, upstream, strategy)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Run(); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// SyncCommands lists the git commands a sync of branches runs, as shown in the
+// TUI's command log: fetching and fast-forwarding every base branch, then
+// updating and pushing each branch with its strategy. The branches of a stack
+// are pushed together once the whole stack is rebased.
+func SyncCommands(config *Config, branches []*Branch) []string {
+	var commands []string
+	for _, base := range RunBases(config, branches) {
+		commands = append(commands, fmt.Sprintf("git fetch %s %s", config.UpstreamRemote, base))
+		if hasLocalBranch(base) {
+			// Like UpdateBaseBranch: in its worktree if it's checked out, else just the ref
+			upstreamRef := config.UpstreamRemote + "/" + base
+			if path, ok := worktreeFor(base); ok {
+				commands = append(commands, fmt.Sprintf("git -C %s merge --ff-only %s", path, upstreamRef))
+			} else {
+				commands = append(commands, fmt.Sprintf("git update-ref refs/heads/%s %s", base, upstreamRef))
+			}
+			commands = append(commands, fmt.Sprintf("git push %s %s --force-with-lease", config.PushRemote(base), base))
+		}
+	}
+
+	var stacked []string
+	for i, b := range branches {
+		onto := b.Parent
+		if onto == "" {
+			base := b.Base
+			if base == "" {
+				base = config.BaseBranch
+			}
+			onto = syncOnto(config, base)
+		}
+
+		switch b.Strategy {
+		case StrategySkip:
+		case StrategyMerge:
+			commands = append(commands, fmt.Sprintf("git merge %s  (on %s)", onto, b.Name))
+		case StrategyFFOnly:
+			commands = append(commands, fmt.Sprintf("git merge --ff-only %s  (on %s)", onto, b.Name))
+		default:
+			commands = append(commands, fmt.Sprintf("git rebase %s %s", onto, b.Name))
+		}
+
+		switch {
+		case b.Stack != "":
+			if b.Strategy != StrategySkip {
+				stacked = append(stacked, b.Name)
+			}
+			if EndsStack(branches, i) && len(stacked) > 0 {
+				commands = append(commands, fmt.Sprintf("git push --atomic --force-with-lease %s %s", config.PushRemote(stacked[0]), strings.Join(stacked, " ")))
+				stacked = nil
+			}
+		case b.Strategy == StrategySkip:
+		case b.Strategy == StrategyMerge || b.Strategy == StrategyFFOnly:
+			commands = append(commands, fmt.Sprintf("git push %s %s", config.PushRemote(b.Name), b.Name))
+		default:
+			commands = append(commands, fmt.Sprintf("git push %s %s --force-with-lease", config.PushRemote(b.Name), b.Name))
+		}
+	}
+	return commands
+}
+
+// FinishSync moves a branch to its rebased commit and pushes it. Only rebased
+// branches are force-pushed (with lease); merged and fast-forwarded ones are
+// pushed normally, so commits others pushed in the meantime are never dropped.
+func FinishSync(r *Rebase, config *Config) error {
+	if err := r.Finish(); err != nil {
+		return err
+	}
+	push := PushBranch
+	if !r.Strategy.ForcePush() {
+		push = PushFastForward
+	}
+	if err := push(r.Branch, config.PushRemote(r.Branch)); err != nil {
+		return ErrPushFailed
+	}
+	return nil
+}
+
+// PushStack force-pushes (with lease) the branches of a stack. The branches
+// going to the same remote are pushed atomically, so the remote never sees a
+// half-updated stack. The lease also guards merged members: it only holds
+// while the remote has nothing we haven't seen.
+func PushStack(branchNames []string, config *Config) error {
+	byRemote := map[string][]string{}
+	var remotes []string
+	for _, name := range branchNames {
+		remote := config.PushRemote(name)
+		if _, ok := byRemote[remote]; !ok {
+			remotes = append(remotes, remote)
+		}
+		byRemote[remote] = append(byRemote[remote], name)
+	}
+
+	for _, remote := range remotes {
+		args := append([]string{"push", "--atomic", "--force-with-lease", remote}, byRemote[remote]...)
+		if err := gitCombined(args...); err != nil {
+			return fmt.Errorf("%w: %w", ErrPushFailed, err)
+		}
+	}
+	return nil
+}
+
+// NeedsStash reports whether syncing the given branches would touch the current
+// checkout while it has uncommitted changes
+func NeedsStash(branchNames []string) bool {
+	for _, name := range branchNames {
+		if NeedsInPlaceRebase(name) {
+			return HasUncommittedChanges()
+		}
+	}
+	return false
+}
+
+// DeleteBranch deletes a branch locally and, optionally, on its push remote.
+// Both are recorded in backup first, so the deletion can be undone. force
+// deletes the local branch even if git doesn't consider it merged.
+func DeleteBranch(branchName string, deleteRemote bool, force bool, config *Config, backup *Backup) error {
+	// Resolve the push remote first: deleting the branch also drops its git config
+	remote := config.PushRemote(branchName)
+	if err := backupRefs(backup, branchName, remote); err != nil {
+		return err
+	}
+
+	// Keep what's needed to restore the branch from the trash
+	entry := TrashEntry{Name: branchName, Description: GetBranchTag(branchName), Remote: remote, DeletedAt: time.Now()}
+	entry.SHA, _ = gitOutput("rev-parse", "--verify", "refs/heads/"+branchName)
+	remoteSHA, _ := gitOutput("rev-parse", "-q", "--verify", "refs/remotes/"+remote+"/"+branchName)
+
+	if err := DeleteLocalBranch(branchName, force); err != nil {
+		return err
+	}
+	var remoteErr error
+	if deleteRemote {
+		if remoteErr = DeleteRemoteBranch(branchName, remote); remoteErr == nil {
+			entry.RemoteSHA = remoteSHA
+			if entry.RemoteSHA == "" {
+				entry.RemoteSHA = entry.SHA
+			}
+		}
+	}
+	if err := RecordTrash(entry); err != nil {
+		return fmt.Errorf("deleted, but could not record it in the trash: %w", err)
+	}
+	if remoteErr != nil {
+		// Special error for partial success
+		return fmt.Errorf("local deleted, but remote failed: %w", remoteErr)
+	}
+	return nil
+}
+
+// backupRefs records a local branch and its copy on the push remote
+func backupRefs(backup *Backup, branchName string, remote string) error {
+	if err := backup.RecordBranch(branchName); err != nil {
+		return err
+	}
+	return backup.RecordRemote(remote, branchName)
+}
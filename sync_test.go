@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSyncCommands(t *testing.T) {
+	fake := useFakeRunner(t)
+	// main is checked out, release/1.2 isn't, and release/1.1 only exists upstream
+	fake.On("worktree list --porcelain", "worktree /repo\nHEAD "+oldSHA+"\nbranch refs/heads/main\n", nil)
+	fake.On("rev-parse --verify -q refs/heads/release/1.1", "", errNoRef)
+
+	config := &Config{BaseBranch: "main", UpstreamRemote: "upstream", OriginRemote: "origin"}
+	branches := []*Branch{
+		{Name: "a", Stack: "a"},
+		{Name: "b", Parent: "a", Stack: "a"},
+		{Name: "shared", Strategy: StrategyMerge},
+		{Name: "hotfix", Base: "release/1.2"},
+		{Name: "backport", Base: "release/1.1"},
+		{Name: "frozen", Strategy: StrategySkip},
+	}
+	want := []string{
+		"git fetch upstream main",
+		"git -C /repo merge --ff-only upstream/main",
+		"git push origin main --force-with-lease",
+		"git fetch upstream release/1.2",
+		"git update-ref refs/heads/release/1.2 upstream/release/1.2",
+		"git push origin release/1.2 --force-with-lease",
+		"git fetch upstream release/1.1",
+		"git rebase main a",
+		"git rebase a b",
+		"git push --atomic --force-with-lease origin a b",
+		"git merge main  (on shared)",
+		"git push origin shared",
+		"git rebase release/1.2 hotfix",
+		"git push origin hotfix --force-with-lease",
+		"git rebase upstream/release/1.1 backport",
+		"git push origin backport --force-with-lease",
+	}
+	if got := SyncCommands(config, branches); !reflect.DeepEqual(got, want) {
+		t.Errorf("SyncCommands() =\n%q\nwant\n%q", got, want)
+	}
+}
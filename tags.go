@@ -1,28 +1,58 @@
 package main
 
-import (
-	"os/exec"
-	"strings"
-)
-
 // GetBranchTag gets the description tag for a branch from git config
 func GetBranchTag(branchName string) string {
-	cmd := exec.Command("git", "config", "branch."+branchName+".description")
-	output, err := cmd.Output()
+	output, err := gitOutput("config", "branch."+branchName+".description")
 	if err != nil {
 		return ""
 	}
-	return strings.TrimSpace(string(output))
+	return output
 }
 
 // SetBranchTag sets the description tag for a branch in git config
 func SetBranchTag(branchName string, description string) error {
-	cmd := exec.Command("git", "config", "branch."+branchName+".description", description)
-	return cmd.Run()
+	return runGit("config", "branch."+branchName+".description", description)
 }
 
 // RemoveBranchTag removes the description tag for a branch
 func RemoveBranchTag(branchName string) error {
-	cmd := exec.Command("git", "config", "--unset", "branch."+branchName+".description")
-	return cmd.Run()
+	return runGit("config", "--unset", "branch."+branchName+".description")
+}
+
+// GetBranchStrategy gets the sync strategy set for a branch in git config, empty if none
+func GetBranchStrategy(branchName string) string {
+	output, err := gitOutput("config", "branch."+branchName+".gitsyncStrategy")
+	if err != nil {
+		return ""
+	}
+	return output
+}
+
+// SetBranchStrategy sets the sync strategy for a branch in git config
+func SetBranchStrategy(branchName string, strategy Strategy) error {
+	return runGit("config", "branch."+branchName+".gitsyncStrategy", string(strategy))
+}
+
+// RemoveBranchStrategy removes the sync strategy for a branch, so the config patterns apply again
+func RemoveBranchStrategy(branchName string) error {
+	return runGit("config", "--unset", "branch."+branchName+".gitsyncStrategy")
+}
+
+// GetBranchBase gets the base branch set for a branch in git config, empty if none
+func GetBranchBase(branchName string) string {
+	output, err := gitOutput("config", "branch."+branchName+".gitsyncBase")
+	if err != nil {
+		return ""
+	}
+	return output
+}
+
+// SetBranchBase sets the base branch of a branch in git config
+func SetBranchBase(branchName string, base string) error {
+	return runGit("config", "branch."+branchName+".gitsyncBase", base)
+}
+
+// RemoveBranchBase removes the base branch of a branch, so the config patterns apply again
+func RemoveBranchBase(branchName string) error {
+	return runGit("config", "--unset", "branch."+branchName+".gitsyncBase")
 }
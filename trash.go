@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// trashFile is the journal of deleted branches, one JSON entry per line, in gitsyncDir
+const trashFile = "trash.jsonl"
+
+// TrashEntry is a deleted branch, recorded so it can be restored
+type TrashEntry struct {
+	Name        string    `json:"name"`
+	SHA         string    `json:"sha"`
+	Description string    `json:"description,omitempty"`
+	Remote      string    `json:"remote,omitempty"`      // push remote the branch was deleted from
+	RemoteSHA   string    `json:"remote_sha,omitempty"`  // the branch on Remote when it was deleted there
+	RemoteOnly  bool      `json:"remote_only,omitempty"` // only deleted on Remote, there was no local branch
+	DeletedAt   time.Time `json:"deleted_at"`
+}
+
+// gitsyncDir returns gitsync's own directory in the repository, .git/gitsync.
+// It lives in the common git dir, so linked worktrees share it.
+func gitsyncDir() (string, error) {
+	dir, err := gitCommonDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gitsync"), nil
+}
+
+// gitCommonDir returns the absolute path of the repository's .git directory,
+// the one shared by all its linked worktrees
+func gitCommonDir() (string, error) {
+	dir, err := gitOutput("rev-parse", "--path-format=absolute", "--git-common-dir")
+	if err != nil {
+		return "", fmt.Errorf("could not find the git directory: %w", err)
+	}
+	return dir, nil
+}
+
+// RecordTrash appends a deleted branch to the trash journal
+func RecordTrash(e TrashEntry) error {
+	dir, err := gitsyncDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, trashFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// ListTrash returns the deleted branches in the trash journal, most recent first
+func ListTrash() ([]TrashEntry, error) {
+	dir, err := gitsyncDir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, trashFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []TrashEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e TrashEntry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil && e.Name != "" {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].DeletedAt.After(entries[j].DeletedAt) })
+	return entries, nil
+}
+
+// RestoreTrash recreates a deleted branch with its description, pushes it back
+// to its remote if it was deleted there, and takes it out of the trash
+func RestoreTrash(e TrashEntry) error {
+	if !e.RemoteOnly {
+		if runGit("rev-parse", "-q", "--verify", "refs/heads/"+e.Name) == nil {
+			return fmt.Errorf("a branch named '%s' already exists", e.Name)
+		}
+		if err := gitCombined("branch", e.Name, e.SHA); err != nil {
+			return fmt.Errorf("failed to restore %s (was the commit garbage-collected?): %w", e.Name, err)
+		}
+		if e.Description != "" {
+			SetBranchTag(e.Name, e.Description)
+		}
+	}
+
+	if e.RemoteSHA != "" {
+		// A plain push: if someone recreated the branch meanwhile, leave theirs alone
+		if err := gitCombined("push", e.Remote, e.RemoteSHA+":refs/heads/"+e.Name); err != nil {
+			if e.RemoteOnly {
+				return fmt.Errorf("failed to push %s back to %s (was the commit garbage-collected?): %w", e.Name, e.Remote, err)
+			}
+			return fmt.Errorf("restored locally, but pushing to %s failed: %w", e.Remote, err)
+		}
+	}
+	return removeTrash(e)
+}
+
+// removeTrash rewrites the trash journal without e
+func removeTrash(e TrashEntry) error {
+	entries, err := ListTrash()
+	if err != nil {
+		return err
+	}
+	var lines []byte
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Name == e.Name && entries[i].SHA == e.SHA && entries[i].DeletedAt.Equal(e.DeletedAt) {
+			continue
+		}
+		data, err := json.Marshal(entries[i])
+		if err != nil {
+			return err
+		}
+		lines = append(append(lines, data...), '\n')
+	}
+	dir, err := gitsyncDir()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, trashFile), lines, 0644)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTrash scripts git to put the trash journal in a temporary directory and returns it
+func useTrash(t *testing.T, fake *FakeRunner) string {
+	t.Helper()
+	dir := t.TempDir()
+	fake.On("rev-parse --path-format=absolute --git-common-dir", dir+"\n", nil)
+	return filepath.Join(dir, "gitsync", trashFile)
+}
+
+func TestDeleteBranchRecordsTrash(t *testing.T) {
+	tests := []struct {
+		name         string
+		deleteRemote bool
+		pushErr      error
+		remoteSHA    string
+		wantErr      bool
+	}{
+		{"local only", false, nil, "", false},
+		{"with remote", true, nil, newSHA, false},
+		{"remote delete failed", true, errNoRef, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeRunner(t)
+			useTrash(t, fake)
+			fake.On("config branch.feat.description", "Login page\n", nil)
+			fake.On("rev-parse --verify refs/heads/feat", oldSHA+"\n", nil)
+			fake.On("rev-parse -q --verify refs/remotes/origin/feat", newSHA+"\n", nil)
+			fake.On("push origin --delete feat", "! [remote rejected] feat (protected branch)", tt.pushErr)
+
+			err := DeleteBranch("feat", tt.deleteRemote, false, &Config{OriginRemote: "origin"}, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DeleteBranch() = %v, want error: %v", err, tt.wantErr)
+			}
+			entries, err := ListTrash()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Fatalf("trash = %+v, want the deleted branch", entries)
+			}
+			e := entries[0]
+			if e.Name != "feat" || e.SHA != oldSHA || e.Description != "Login page" || e.Remote != "origin" || e.RemoteSHA != tt.remoteSHA {
+				t.Errorf("trash entry = %+v, want feat at %s, remote SHA %q", e, oldSHA, tt.remoteSHA)
+			}
+		})
+	}
+}
+
+func TestRestoreTrash(t *testing.T) {
+	fake := useFakeRunner(t)
+	journal := useTrash(t, fake)
+	deleted := time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC)
+	kept := TrashEntry{Name: "other", SHA: newSHA, DeletedAt: deleted.Add(time.Hour)}
+	feat := TrashEntry{Name: "feat", SHA: oldSHA, Description: "Login page", Remote: "origin", RemoteSHA: newSHA, DeletedAt: deleted}
+	for _, e := range []TrashEntry{feat, kept} {
+		if err := RecordTrash(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A branch with the same name was created since
+	if err := RestoreTrash(feat); err == nil || fake.Called("branch feat "+oldSHA) {
+		t.Errorf("RestoreTrash() over an existing branch = %v", err)
+	}
+
+	fake.On("rev-parse -q --verify refs/heads/feat", "", errNoRef)
+	if err := RestoreTrash(feat); err != nil {
+		t.Fatal(err)
+	}
+	checkCalls(t, fake, []string{
+		"branch feat " + oldSHA,
+		"config branch.feat.description Login page",
+		"push origin " + newSHA + ":refs/heads/feat",
+	}, nil)
+
+	entries, err := ListTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != "other" {
+		t.Errorf("trash after restoring feat = %+v, want only other", entries)
+	}
+	data, _ := os.ReadFile(journal)
+	if strings.Count(string(data), "\n") != 1 {
+		t.Errorf("journal =\n%s", data)
+	}
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"slices"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/truncate"
 )
 
 // Styles
@@ -66,6 +71,17 @@ const (
 	stateCheckoutList
 	stateCheckoutNew
 	stateCheckoutNewFrom
+	stateConflict
+	stateUndo
+	statePlan
+	stateEditingBase
+	stateCleanup
+	stateArchived
+	stateTrash
+	stateConfirmingForce
+	stateRemoteBranches
+	stateConfirmingRemoteDelete
+	stateLog
 )
 
 // Model represents the application state
@@ -87,11 +103,86 @@ type Model struct {
 	commandLog             []string
 	searchMode             bool
 	searchQuery            string
-	loadingDots            string // For animating the loading message
-	deleteMode             bool   // Are we in deletion mode?
-	deleteRemote           bool   // Should we delete the remote branch?
+	statusFilter           string            // only show branches with this status, see statusFilters
+	loadingDots            string            // For animating the loading message
+	deleteMode             bool              // Are we in deletion mode?
+	deleteRemote           bool              // Should we delete the remote branch?
+	archive                bool              // Delete mode archives branches to refs/archive instead of deleting them
+	unmerged               []*UnmergedBranch // Selected branches 'git branch -d' would refuse, being confirmed one by one
+	unmergedIndex          int
+	forceDelete            map[string]bool // Unmerged branches confirmed for 'git branch -D'
 	selectedForActionCount int
-	didStash               bool // Did we stash changes?
+	restore                *RestorePoint // Where to return to once a sync or delete run ends
+	restoreErr             string        // Set when the teardown could not restore everything
+	cancelling             bool          // ctrl+c was pressed during a run
+	backup                 *Backup       // Old SHAs of every ref the current run touched
+	dryRun                 bool          // enter previews the sync instead of running it
+	plan                   *SyncPlan     // Predicted outcome of the selected sync, if previewed
+	stacked                []string      // Rebased stack members waiting to be pushed together
+	failedNames            map[string]bool
+	skippedBranches        []string          // Selected branches left alone because their strategy is skip
+	baseErrs               map[string]error  // Base branches that could not be updated this run
+	deletedSHAs            map[string]string // Commits of the branches deleted this run, by name
+	width                  int               // Terminal size, from the last tea.WindowSizeMsg
+	height                 int
+
+	// Conflict resolution fields
+	conflict     *Rebase       // Rebase paused on a conflict
+	conflictInfo *ConflictInfo // Conflicted files and the commit being replayed
+	conflictNote string        // Feedback from the last resolution action
+
+	// Base editing fields
+	baseBranch string // Branch whose base is being edited
+	baseInput  string
+
+	// Cleanup fields
+	cleanupLoading bool // candidates are still being classified
+	cleanupCursor  int
+
+	// Details panel fields
+	detailsHidden  bool                      // The details panel was turned off with 'v'
+	details        map[string]*BranchDetails // Loaded details, by branch name
+	detailsPending map[string]bool           // Branches whose details are being loaded
+
+	// Log viewer fields
+	logBranch    *Branch
+	logFull      bool     // Show the branch's whole history instead of base..branch
+	logDiff      bool     // Show the branch's diff instead of its log
+	logLines     []string // Colored 'git log' output
+	logLoading   bool
+	logErr       string
+	logOffset    int  // First line shown
+	logSearching bool // The search query is being typed
+	logQuery     string
+	logMatches   []int // Lines matching logQuery
+	logMatch     int   // Current match in logMatches
+
+	// Remote branch fields
+	remote         string          // Push remote whose branches are listed
+	remoteBranches []*RemoteBranch // Branches on remote, most recent commit first
+	remoteSelected map[string]bool
+	remoteCursor   int
+	remoteLoading  bool
+	remoteNote     string // Outcome of the last delete
+	remoteChanged  bool   // Branches were deleted, so branch info must be reloaded
+
+	// Archive fields
+	archived        []ArchivedBranch // Archived branches, most recent first
+	archivedCursor  int
+	archivedNote    string // Outcome of the last restore
+	archivedChanged bool   // A branch was restored, so branch info must be reloaded
+
+	// Trash fields
+	trash        []TrashEntry // Deleted branches, most recent first
+	trashCursor  int
+	trashNote    string // Outcome of the last restore
+	trashChanged bool   // A branch was restored, so branch info must be reloaded
+
+	// Undo fields
+	undoRuns    []BackupRun // Backups, newest first
+	undoCursor  int
+	undoNote    string // Outcome of the last restore
+	undoChanged bool   // A backup was restored, so branch info must be reloaded
 
 	// Checkout mode fields
 	checkoutCursor      int
@@ -118,14 +209,109 @@ type branchUpdatedMsg struct {
 	branch  string
 	success bool
 	error   string
+	stacked bool // rebased but not pushed yet, the stack is pushed as a whole
+	skipped bool // left alone, the branch's strategy is skip
+}
+
+type basesPreparedMsg struct {
+	errs map[string]error // base branches that could not be updated
+}
+
+type stackPushedMsg struct {
+	branches []string
+	err      error
+}
+
+type unmergedCheckedMsg struct {
+	unmerged []*UnmergedBranch
+	err      error
 }
 
 type branchDeletedMsg struct {
 	branch  string
+	sha     string // the branch's commit before it was deleted
 	success bool
 	error   string
 }
 
+type conflictMsg struct {
+	rebase *Rebase
+	info   *ConflictInfo
+	note   string
+}
+
+type conflictToolDoneMsg struct {
+	err error
+}
+
+type branchReloadedMsg struct {
+	branch *Branch
+	err    error // the branch's base branch could not be fetched
+}
+
+type planMsg struct {
+	plan *SyncPlan
+	err  error
+}
+
+type cleanupFoundMsg struct {
+	candidates []CleanupCandidate
+	err        error
+}
+
+type detailsLoadedMsg struct {
+	branch  string
+	details *BranchDetails
+}
+
+type logLoadedMsg struct {
+	branch string
+	full   bool
+	diff   bool
+	lines  []string
+	err    error
+}
+
+type remoteBranchesMsg struct {
+	branches []*RemoteBranch
+	err      error
+}
+
+type remoteDeletedMsg struct {
+	deleted []string
+	err     error
+}
+
+type archivedListMsg struct {
+	archived []ArchivedBranch
+	err      error
+}
+
+type archiveRestoredMsg struct {
+	name string
+	err  error
+}
+
+type trashListMsg struct {
+	entries []TrashEntry
+	err     error
+}
+
+type trashRestoredMsg struct {
+	name string
+	err  error
+}
+
+type undoListMsg struct {
+	runs []BackupRun
+	err  error
+}
+
+type undoDoneMsg struct {
+	restored []string
+	err      error
+}
+
 type checkoutMsg struct {
 	err error
 }
@@ -135,8 +321,10 @@ type tickMsg time.Time
 // InitialModel creates the initial model
 func InitialModel() Model {
 	return Model{
-		state:   stateLoading,
-		message: "Loading repository information...",
+		state:          stateLoading,
+		message:        "Loading repository information...",
+		details:        map[string]*BranchDetails{},
+		detailsPending: map[string]bool{},
 	}
 }
 
@@ -159,9 +347,11 @@ func loadRepoInfo() tea.Msg {
 		return errorMsg{err}
 	}
 
-	// Fetch the latest from upstream before loading branches
-	if err := FetchUpstream(config.UpstreamRemote, config.BaseBranch); err != nil {
-		return errorMsg{fmt.Errorf("failed to fetch upstream '%s/%s': %w", config.UpstreamRemote, config.BaseBranch, err)}
+	// Fetch the latest from upstream before loading branches, unless fetch_on_start is off
+	if config.FetchOnStart {
+		if err := FetchBases(config); err != nil {
+			return errorMsg{fmt.Errorf("failed to fetch upstream '%s/%s': %w", config.UpstreamRemote, config.BaseBranch, err)}
+		}
 	}
 
 	current, err := GetCurrentBranch()
@@ -169,10 +359,19 @@ func loadRepoInfo() tea.Msg {
 		return errorMsg{err}
 	}
 
-	branches, err := GetBranchesWithInfo(config.BaseBranch, config.UpstreamRemote, config.ExcludePatterns)
+	filter, err := config.BranchFilter()
+	if err != nil {
+		return errorMsg{err}
+	}
+
+	branches, err := GetBranchesWithInfo(config, filter)
 	if err != nil {
 		return errorMsg{err}
 	}
+	// Asking the push remotes where their branches are goes to the network too
+	if config.FetchOnStart {
+		CheckRemoteHeads(branches)
+	}
 
 	allBranches, err := GetAllBranches()
 	if err != nil {
@@ -191,7 +390,22 @@ func loadRepoInfo() tea.Msg {
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		return m.handleKeyPress(msg)
+		model, cmd := m.handleKeyPress(msg)
+		if next, ok := model.(Model); ok && next.state == stateBrowsing {
+			// The cursor may have moved to a branch whose details aren't loaded yet
+			return next, tea.Batch(cmd, next.loadDetails())
+		}
+		return model, cmd
+
+	case detailsLoadedMsg:
+		delete(m.detailsPending, msg.branch)
+		m.details[msg.branch] = msg.details
+		return m, nil
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		return m, nil
 
 	case loadedMsg:
 		m.branches = msg.branches
@@ -201,17 +415,196 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.originalBranch = msg.current
 		m.state = stateBrowsing
 		m.message = ""
-		return m, nil
+		// Branches may have moved, so drop the details loaded before
+		m.details = map[string]*BranchDetails{}
+		m.detailsPending = map[string]bool{}
+		return m, m.loadDetails()
 
 	case errorMsg:
 		m.state = stateError
 		m.error = msg.err.Error()
-		if m.didStash {
-			StashPop()
-			m.didStash = false
+		m.teardown()
+		return m, nil
+
+	case conflictMsg:
+		m.state = stateConflict
+		m.conflict = msg.rebase
+		m.conflictInfo = msg.info
+		m.conflictNote = msg.note
+		if m.cancelling {
+			// ctrl+c was pressed while this branch was rebasing
+			return m, m.abortConflict()
+		}
+		return m, nil
+
+	case conflictToolDoneMsg:
+		m.conflictNote = ""
+		if msg.err != nil {
+			m.conflictNote = msg.err.Error()
+		}
+		if info, err := m.conflict.Conflict(); err == nil {
+			m.conflictInfo = info
+		}
+		return m, nil
+
+	case branchReloadedMsg:
+		for i, b := range m.branches {
+			if b.Name == msg.branch.Name {
+				msg.branch.Selected = b.Selected
+				msg.branch.Parent, msg.branch.ParentSHA, msg.branch.Depth, msg.branch.Stack = b.Parent, b.ParentSHA, b.Depth, b.Stack
+				m.branches[i] = msg.branch
+			}
+		}
+		b := msg.branch
+		m.message = fmt.Sprintf("%s: based on %s (%s)", b.Name, b.Base, b.BaseSource)
+		if msg.err != nil {
+			m.message = fmt.Sprintf("%s: could not fetch %s/%s", b.Name, m.config.UpstreamRemote, b.Base)
+		}
+		// Its base changed, so its details did too
+		delete(m.details, b.Name)
+		return m, m.loadDetails()
+
+	case planMsg:
+		if msg.err != nil {
+			m.state = stateError
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		m.state = statePlan
+		m.plan = msg.plan
+		m.message = ""
+		return m, nil
+
+	case cleanupFoundMsg:
+		if m.state != stateCleanup || !m.cleanupLoading {
+			// The user left the cleanup view while it was loading
+			return m, nil
+		}
+		if msg.err != nil {
+			m.state = stateError
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		m.cleanupLoading = false
+		for _, c := range msg.candidates {
+			for _, b := range m.branches {
+				if b.Name == c.Name {
+					c := c
+					b.Cleanup = &c
+					b.Selected = true
+				}
+			}
+		}
+		return m, nil
+
+	case logLoadedMsg:
+		if m.logBranch == nil || msg.branch != m.logBranch.Name || msg.full != m.logFull || msg.diff != m.logDiff {
+			// The user toggled the view or left while loading
+			return m, nil
+		}
+		m.logLoading = false
+		m.logLines = msg.lines
+		m.logErr = ""
+		if msg.err != nil {
+			m.logErr = msg.err.Error()
+		}
+		m.logOffset = 0
+		m.findLogMatches()
+		return m, nil
+
+	case remoteBranchesMsg:
+		if msg.err != nil {
+			m.state = stateError
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		m.remoteLoading = false
+		m.remoteBranches = msg.branches
+		if m.remoteCursor >= len(m.remoteBranches) {
+			m.remoteCursor = 0
+		}
+		return m, nil
+
+	case remoteDeletedMsg:
+		m.state = stateRemoteBranches
+		m.remoteNote = fmt.Sprintf("✓ Deleted %d branch(es) on %s. Restore them from the trash (T) or undo (u, then r).", len(msg.deleted), m.remote)
+		if msg.err != nil {
+			m.remoteNote = fmt.Sprintf("✗ Deleted %d branch(es), but %v", len(msg.deleted), msg.err)
+		}
+		if len(msg.deleted) > 0 {
+			m.remoteChanged = true
+		}
+		m.remoteSelected = map[string]bool{}
+		m.remoteLoading = true
+		config, remote := m.config, m.remote
+		return m, func() (msg tea.Msg) {
+			defer recoverAsError(&msg)
+			return loadRemoteBranches(config, remote)
+		}
+
+	case archivedListMsg:
+		if msg.err != nil {
+			m.state = stateError
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		m.archived = msg.archived
+		if m.archivedCursor >= len(m.archived) {
+			m.archivedCursor = 0
+		}
+		return m, nil
+
+	case archiveRestoredMsg:
+		if msg.err != nil {
+			m.archivedNote = "✗ " + msg.err.Error()
+		} else {
+			m.archivedNote = "✓ Restored " + msg.name
+		}
+		m.archivedChanged = true
+		return m, loadArchived
+
+	case trashListMsg:
+		if msg.err != nil {
+			m.state = stateError
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		m.trash = msg.entries
+		if m.trashCursor >= len(m.trash) {
+			m.trashCursor = 0
+		}
+		return m, nil
+
+	case trashRestoredMsg:
+		if msg.err != nil {
+			m.trashNote = "✗ " + msg.err.Error()
+		} else {
+			m.trashNote = "✓ Restored " + msg.name
+		}
+		m.trashChanged = true
+		return m, loadTrash
+
+	case undoListMsg:
+		if msg.err != nil {
+			m.state = stateError
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		m.undoRuns = msg.runs
+		if m.undoCursor >= len(m.undoRuns) {
+			m.undoCursor = 0
 		}
 		return m, nil
 
+	case undoDoneMsg:
+		m.undoChanged = true
+		m.undoNote = "✓ Restored " + strings.Join(msg.restored, ", ")
+		if msg.err != nil {
+			m.undoNote = "✗ " + msg.err.Error()
+		}
+		// The restore backed up the current state, so it shows up as a new run
+		return m, loadBackups
+
 	case checkoutMsg:
 		if msg.err != nil {
 			m.state = stateError
@@ -224,41 +617,63 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.error = "" // Clear any previous error
 		return m, loadRepoInfo
 
+	case basesPreparedMsg:
+		m.baseErrs = msg.errs
+		if m.cancelling {
+			return m.finishRun()
+		}
+		return m, m.updateNextBranch()
+
 	case branchUpdatedMsg:
-		if msg.success {
-			m.successCount++
-			// Update branch status AND info
-			for i, b := range m.branches {
+		m.conflict = nil
+		if msg.skipped {
+			m.skippedBranches = append(m.skippedBranches, msg.branch)
+		} else if msg.success && msg.stacked {
+			m.stacked = append(m.stacked, msg.branch)
+			for _, b := range m.branches {
 				if b.Name == msg.branch {
-					// Re-fetch info for the updated branch
-					updatedBranchInfo, err := GetBranchInfo(b.Name, m.config.BaseBranch, m.config.UpstreamRemote)
-					if err == nil {
-						// Preserve selection status
-						updatedBranchInfo.Selected = b.Selected
-						m.branches[i] = updatedBranchInfo
-						m.branches[i].Status = "updated"
-					}
-					break
+					b.Status = "rebased"
 				}
 			}
+		} else if msg.success {
+			m.successCount++
+			m.refreshBranch(msg.branch)
 		} else {
+			m.failedNames[msg.branch] = true
 			m.failedBranches = append(m.failedBranches, fmt.Sprintf("%s (%s)", msg.branch, msg.error))
 		}
 
 		m.updateIndex++
 
-		if m.updateIndex >= m.selectedForActionCount {
-			m.state = stateDone
-			if m.didStash {
-				StashPop()
-				m.didStash = false
-			}
-			return m, nil
+		// Push a stack once its last branch is done (or the run stops early)
+		done := m.updateIndex >= m.selectedForActionCount || m.cancelling
+		if len(m.stacked) > 0 && (done || EndsStack(m.selectedBranches(), m.updateIndex-1)) {
+			return m, m.pushStack()
+		}
+		if done {
+			return m.finishRun()
 		}
 
 		// Update next branch
 		return m, m.updateNextBranch()
 
+	case stackPushedMsg:
+		for _, name := range msg.branches {
+			if msg.err != nil {
+				m.failedNames[name] = true
+				m.failedBranches = append(m.failedBranches, fmt.Sprintf("%s (%s)", name, msg.err))
+				continue
+			}
+			m.successCount++
+			m.refreshBranch(name)
+		}
+		m.stacked = nil
+
+		if m.updateIndex >= m.selectedForActionCount || m.cancelling {
+			return m.finishRun()
+		}
+		return m, m.updateNextBranch()
+
 	case tickMsg:
 		if m.state == stateLoading {
 			if len(m.loadingDots) < 3 {
@@ -269,6 +684,20 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tick() // Continue ticking
 		}
 
+	case unmergedCheckedMsg:
+		if msg.err != nil {
+			m.state = stateError
+			m.error = msg.err.Error()
+			return m, nil
+		}
+		if len(msg.unmerged) == 0 {
+			return m.startDeleteRun()
+		}
+		m.unmerged = msg.unmerged
+		m.unmergedIndex = 0
+		m.state = stateConfirmingForce
+		return m, nil
+
 	case branchDeletedMsg:
 		if msg.success {
 			m.successCount++
@@ -276,6 +705,10 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			for _, b := range m.branches {
 				if b.Name == msg.branch {
 					b.Status = "deleted"
+					m.deletedSHAs[b.Name] = msg.sha
+					if m.archive {
+						b.Status = "archived"
+					}
 					break
 				}
 			}
@@ -285,13 +718,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		m.updateIndex++
 
-		if m.updateIndex >= m.selectedForActionCount {
-			m.state = stateDone
-			if m.didStash {
-				StashPop()
-				m.didStash = false
-			}
-			return m, nil
+		if m.updateIndex >= m.selectedForActionCount || m.cancelling {
+			return m.finishRun()
 		}
 
 		// Delete next branch
@@ -312,6 +740,8 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.handleConfirmingDeleteKeys(msg)
 	case stateConfirmingDeleteType:
 		return m.handleConfirmingDeleteTypeKeys(msg)
+	case stateConfirmingForce:
+		return m.handleConfirmingForceKeys(msg)
 	case stateConfirmingStash:
 		return m.handleConfirmingStashKeys(msg)
 	case stateCheckoutList:
@@ -321,7 +751,7 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case stateCheckoutNewFrom:
 		return m.handleCheckoutNewFromKeys(msg)
 	case stateDone, stateError:
-		if msg.String() == " " || msg.String() == "enter" {
+		if msg.String() == " " || msg.String() == "enter" || msg.String() == "u" || msg.String() == "T" {
 			m.state = stateBrowsing
 			m.message = ""
 			m.error = ""
@@ -330,21 +760,55 @@ func (m Model) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.updateIndex = 0
 			m.selectedForActionCount = 0
 			m.commandLog = []string{}
-			m.didStash = false
+			m.restore = nil
+			m.restoreErr = ""
+			m.cancelling = false
 			m.deleteMode = false
+			m.plan = nil
 			for _, b := range m.branches {
 				b.Selected = false
+				b.Cleanup = nil
+			}
+			if msg.String() == "u" {
+				return m.openUndo()
+			}
+			if msg.String() == "T" {
+				return m.openTrash()
 			}
 			return m, nil
 		} else if msg.String() == "q" || msg.String() == "ctrl+c" {
-			if m.didStash {
-				StashPop()
-			}
+			m.teardown()
 			m.deleteMode = false
 			return m, tea.Quit
 		}
+	case stateUpdating, stateDeleting:
+		if msg.String() == "ctrl+c" && !m.cancelling {
+			// Let the current branch finish, then tear down and stop
+			m.cancelling = true
+			m.message = "Cancelling after the current branch..."
+		}
+	case stateConflict:
+		return m.handleConflictKeys(msg)
+	case stateUndo:
+		return m.handleUndoKeys(msg)
+	case stateCleanup:
+		return m.handleCleanupKeys(msg)
+	case stateLog:
+		return m.handleLogKeys(msg)
+	case stateRemoteBranches:
+		return m.handleRemoteBranchesKeys(msg)
+	case stateConfirmingRemoteDelete:
+		return m.handleConfirmingRemoteDeleteKeys(msg)
+	case stateArchived:
+		return m.handleArchivedKeys(msg)
+	case stateTrash:
+		return m.handleTrashKeys(msg)
+	case statePlan:
+		return m.handlePlanKeys(msg)
 	case stateTagging:
 		return m.handleTaggingKeys(msg)
+	case stateEditingBase:
+		return m.handleEditingBaseKeys(msg)
 	case stateHelp:
 		return m.handleHelpKeys(msg)
 	}
@@ -428,6 +892,67 @@ func (m Model) handleBrowsingKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "h":
 		m.state = stateHelp
 
+	case "u":
+		return m.openUndo()
+
+	case "x":
+		return m.openCleanup()
+
+	case "l":
+		// Show the log of the current branch
+		filtered := m.getFilteredBranches()
+		if m.cursor < len(filtered) {
+			return m.openLog(filtered[m.cursor], false)
+		}
+
+	case "D":
+		// Show the full diff of the current branch
+		filtered := m.getFilteredBranches()
+		if m.cursor < len(filtered) {
+			return m.openLog(filtered[m.cursor], true)
+		}
+
+	case "v":
+		// Show or hide the details panel
+		m.detailsHidden = !m.detailsHidden
+
+	case "R":
+		return m.openRemoteBranches()
+
+	case "A":
+		return m.openArchived()
+
+	case "T":
+		return m.openTrash()
+
+	case "p":
+		m.dryRun = !m.dryRun
+		m.message = ""
+		if m.dryRun {
+			m.message = "DRY RUN: enter previews the sync without changing anything. Press 'p' to turn it off."
+		}
+
+	case "s":
+		// Cycle the sync strategy of the current branch
+		filtered := m.getFilteredBranches()
+		if m.cursor < len(filtered) {
+			branch := filtered[m.cursor]
+			if err := cycleStrategy(m.config, branch); err != nil {
+				m.message = fmt.Sprintf("Failed to set the strategy of %s: %v", branch.Name, err)
+			} else {
+				m.message = fmt.Sprintf("%s: %s (%s)", branch.Name, branch.Strategy, branch.StrategySource)
+			}
+		}
+
+	case "b":
+		// Edit the base branch of the current branch
+		filtered := m.getFilteredBranches()
+		if m.cursor < len(filtered) {
+			m.state = stateEditingBase
+			m.baseBranch = filtered[m.cursor].Name
+			m.baseInput = GetBranchBase(m.baseBranch)
+		}
+
 	case "t":
 		// Tag current branch
 		filtered := m.getFilteredBranches()
@@ -443,10 +968,22 @@ func (m Model) handleBrowsingKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.cursor = 0
 		return m, nil
 
+	case "f":
+		// Cycle the status filter
+		for i, f := range statusFilters {
+			if f == m.statusFilter {
+				m.statusFilter = statusFilters[(i+1)%len(statusFilters)]
+				break
+			}
+		}
+		m.cursor = 0
+		return m, nil
+
 	case "esc":
-		// Clear search or exit delete mode
-		if m.searchQuery != "" {
+		// Clear search and status filter or exit delete mode
+		if m.searchQuery != "" || m.statusFilter != "" {
 			m.searchQuery = ""
+			m.statusFilter = ""
 			m.cursor = 0
 			return m, nil
 		}
@@ -465,53 +1002,55 @@ func (m Model) handleBrowsingKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			// Disable enter key in delete mode
 			return m, nil
 		}
-		// Check for uncommitted changes before starting
-		if HasUncommittedChanges() {
-			m.state = stateConfirmingStash
-			m.message = "You have uncommitted changes. Stash them and proceed? (y/n)"
-			return m, nil
+		if m.dryRun {
+			return m.previewSync()
 		}
+		m.plan = nil
+		return m.startSync()
+	}
 
-		// Start update process
-		selectedCount := 0
-		for _, b := range m.branches {
-			if b.Selected {
-				selectedCount++
-			}
-		}
+	return m, nil
+}
 
-		if selectedCount == 0 {
-			m.message = "No branches selected"
-			return m, nil
-		}
+// startSync starts syncing the selected branches, asking to stash or confirm first if needed
+func (m Model) startSync() (tea.Model, tea.Cmd) {
+	m.selectStacks()
 
-		// Populate command log
-		m.commandLog = []string{}
-		m.commandLog = append(m.commandLog, fmt.Sprintf("git fetch %s %s", m.config.UpstreamRemote, m.config.BaseBranch))
-		m.commandLog = append(m.commandLog, fmt.Sprintf("git checkout %s", m.config.BaseBranch))
-		m.commandLog = append(m.commandLog, fmt.Sprintf("git reset --hard %s/%s", m.config.UpstreamRemote, m.config.BaseBranch))
-		m.commandLog = append(m.commandLog, fmt.Sprintf("git push origin %s --force-with-lease", m.config.BaseBranch))
-		for _, b := range m.branches {
-			if b.Selected {
-				m.commandLog = append(m.commandLog, fmt.Sprintf("git checkout %s", b.Name))
-				m.commandLog = append(m.commandLog, fmt.Sprintf("git rebase %s", m.config.BaseBranch))
-				m.commandLog = append(m.commandLog, fmt.Sprintf("git push origin %s --force-with-lease", b.Name))
-			}
-		}
+	// Syncs run in temporary worktrees; only ask to stash if the
+	// checked-out branch itself has to be rebased in place
+	if NeedsStash(m.selectedBranchNames()) {
+		m.state = stateConfirmingStash
+		m.message = "You have uncommitted changes. Stash them and proceed? (y/n)"
+		return m, nil
+	}
 
-		if manualMode {
-			m.state = stateConfirming
-			m.message = fmt.Sprintf("Ready to update %d branch(es). Press 'y' to continue, 'n' to cancel.", selectedCount)
-		} else {
-			m.state = stateUpdating
-			m.updateIndex = 0
-			m.successCount = 0
-			m.failedBranches = []string{}
-			m.selectedForActionCount = selectedCount
-			return m, m.updateNextBranch()
+	// Start update process
+	selectedCount := 0
+	for _, b := range m.branches {
+		if b.Selected {
+			selectedCount++
 		}
 	}
 
+	if selectedCount == 0 {
+		m.message = "No branches selected"
+		return m, nil
+	}
+
+	if m.config.ManualMode {
+		m.state = stateConfirming
+		m.message = fmt.Sprintf("Ready to update %d branch(es). Press 'y' to continue, 'n' to cancel.", selectedCount)
+	} else {
+		m.beginRun()
+		m.state = stateUpdating
+		m.updateIndex = 0
+		m.successCount = 0
+		m.failedBranches = []string{}
+		m.selectedForActionCount = selectedCount
+		m.commandLog = SyncCommands(m.config, m.selectedBranches())
+		return m, m.prepareBases()
+	}
+
 	return m, nil
 }
 
@@ -543,17 +1082,84 @@ func (m Model) handleSearchKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-// getFilteredBranches returns branches that match the search query
-func (m Model) getFilteredBranches() []*Branch {
-	if m.searchQuery == "" {
-		return m.branches
+// selectedBranches returns the selected branches, in tree order
+func (m Model) selectedBranches() []*Branch {
+	var selected []*Branch
+	for _, b := range m.branches {
+		if b.Selected {
+			selected = append(selected, b)
+		}
 	}
+	return selected
+}
 
-	var filtered []*Branch
-	query := strings.ToLower(m.searchQuery)
+// selectStacks selects the rest of every stack that has a selected branch, since stacks are synced as a whole
+func (m Model) selectStacks() {
+	for _, b := range ExpandStacks(m.branches, m.selectedBranchNames()) {
+		b.Selected = true
+	}
+}
 
-	for _, branch := range m.branches {
-		// Search in branch name and description
+// refreshBranch reloads the info of a synced branch and marks it updated.
+// Its stack position is kept, and its children now start after its new tip.
+func (m Model) refreshBranch(name string) {
+	for i, b := range m.branches {
+		if b.Name != name {
+			continue
+		}
+		updated, err := GetBranchInfo(b.Name, m.config)
+		if err != nil {
+			return
+		}
+		updated.Selected = b.Selected
+		updated.Parent, updated.ParentSHA, updated.Depth, updated.Stack = b.Parent, b.ParentSHA, b.Depth, b.Stack
+		updated.Status = "updated"
+		m.branches[i] = updated
+
+		if tip, err := gitOutput("rev-parse", "refs/heads/"+name); err == nil {
+			for _, child := range m.branches {
+				if child.Parent == name {
+					child.ParentSHA = tip
+				}
+			}
+		}
+		return
+	}
+}
+
+// selectedBranchNames returns the names of the selected branches
+func (m Model) selectedBranchNames() []string {
+	var names []string
+	for _, b := range m.branches {
+		if b.Selected {
+			names = append(names, b.Name)
+		}
+	}
+	return names
+}
+
+// statusFilters are the status filters the 'f' key cycles through; "" shows every branch
+var statusFilters = append([]string{"", "behind"}, OriginStatuses...)
+
+// filtering reports whether the branch list is narrowed by a search or status filter
+func (m Model) filtering() bool {
+	return m.searchQuery != "" || m.statusFilter != ""
+}
+
+// getFilteredBranches returns branches that match the search query and status filter
+func (m Model) getFilteredBranches() []*Branch {
+	if !m.filtering() {
+		return m.branches
+	}
+
+	var filtered []*Branch
+	query := strings.ToLower(m.searchQuery)
+
+	for _, branch := range m.branches {
+		if m.statusFilter != "" && !branch.HasStatus(m.statusFilter) {
+			continue
+		}
+		// Search in branch name and description
 		if strings.Contains(strings.ToLower(branch.Name), query) ||
 			strings.Contains(strings.ToLower(branch.Description), query) {
 			filtered = append(filtered, branch)
@@ -567,6 +1173,7 @@ func (m Model) getFilteredBranches() []*Branch {
 func (m Model) handleConfirmingKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "y", "Y":
+		m.beginRun()
 		m.state = stateUpdating
 		m.updateIndex = 0
 		m.successCount = 0
@@ -577,24 +1184,13 @@ func (m Model) handleConfirmingKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.selectedForActionCount++
 			}
 		}
-		// Populate command log
-		m.commandLog = []string{}
-		m.commandLog = append(m.commandLog, fmt.Sprintf("git fetch %s %s", m.config.UpstreamRemote, m.config.BaseBranch))
-		m.commandLog = append(m.commandLog, fmt.Sprintf("git checkout %s", m.config.BaseBranch))
-		m.commandLog = append(m.commandLog, fmt.Sprintf("git reset --hard %s/%s", m.config.UpstreamRemote, m.config.BaseBranch))
-		m.commandLog = append(m.commandLog, fmt.Sprintf("git push origin %s --force-with-lease", m.config.BaseBranch))
-		for _, b := range m.branches {
-			if b.Selected {
-				m.commandLog = append(m.commandLog, fmt.Sprintf("git checkout %s", b.Name))
-				m.commandLog = append(m.commandLog, fmt.Sprintf("git rebase %s", m.config.BaseBranch))
-				m.commandLog = append(m.commandLog, fmt.Sprintf("git push origin %s --force-with-lease", b.Name))
-			}
-		}
-		return m, m.updateNextBranch()
+		m.commandLog = SyncCommands(m.config, m.selectedBranches())
+		return m, m.prepareBases()
 
 	case "n", "N", "q", "ctrl+c":
 		m.state = stateBrowsing
 		m.message = "Update cancelled"
+		m.cancelRun()
 	}
 
 	return m, nil
@@ -610,39 +1206,131 @@ func (m Model) handleConfirmingDeleteKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case "n", "N", "q", "ctrl+c", "esc":
-		m.state = stateBrowsing
-		m.deleteMode = false
-		m.message = "Deletion cancelled"
-		// Deselect all branches
-		for _, b := range m.branches {
-			b.Selected = false
-		}
+		m.cancelDelete()
 	}
 
 	return m, nil
 }
 
+// cancelDelete goes back to browsing without deleting anything. The branches
+// lose their selection and their cleanup classification, so a later ordinary
+// delete doesn't force-delete them without the unmerged check.
+func (m *Model) cancelDelete() {
+	m.state = stateBrowsing
+	m.deleteMode = false
+	m.message = "Deletion cancelled"
+	for _, b := range m.branches {
+		b.Selected = false
+		b.Cleanup = nil
+	}
+}
+
 // handleConfirmingDeleteTypeKeys handles keys in the delete type confirmation state
 func (m Model) handleConfirmingDeleteTypeKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
-	case "1":
-		m.deleteRemote = false
+	case "enter", "1":
+		m.archive, m.deleteRemote = true, false
 	case "2":
-		m.deleteRemote = true
+		m.archive, m.deleteRemote = true, true
+	case "3":
+		m.archive, m.deleteRemote = false, false
+	case "4":
+		m.archive, m.deleteRemote = false, true
 	case "n", "N", "q", "ctrl+c", "esc":
-		m.state = stateBrowsing
-		m.deleteMode = false
-		m.message = "Deletion cancelled"
+		m.cancelDelete()
+		return m, nil
+	default:
+		// Any other key is ignored
+		return m, nil
+	}
+
+	// This part is reached only if one of the options was picked
+	m.forceDelete = map[string]bool{}
+	if m.archive {
+		return m.startDeleteRun()
+	}
+
+	// Find the branches 'git branch -d' would refuse before deleting anything
+	var names, deleting []string
+	for _, b := range m.branches {
+		if b.Selected {
+			deleting = append(deleting, b.Name)
+			if !m.forceDeletes(b) {
+				names = append(names, b.Name)
+			}
+		}
+	}
+	var deletingRemote []string
+	if m.deleteRemote {
+		deletingRemote = remoteCopies(m.config, deleting)
+	}
+	m.message = "Checking for unmerged branches..."
+	return m, func() (msg tea.Msg) {
+		defer recoverAsError(&msg)
+		var unmerged []*UnmergedBranch
+		for _, name := range names {
+			u, err := CheckUnmerged(name, deleting, deletingRemote)
+			if err != nil {
+				return unmergedCheckedMsg{err: err}
+			}
+			if u != nil {
+				unmerged = append(unmerged, u)
+			}
+		}
+		return unmergedCheckedMsg{unmerged: unmerged}
+	}
+}
+
+// handleConfirmingForceKeys asks, one unmerged branch at a time, whether to force-delete it
+func (m Model) handleConfirmingForceKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	u := m.unmerged[m.unmergedIndex]
+	switch msg.String() {
+	case "y":
+		m.forceDelete[u.Name] = true
+	case "a":
+		// Force-delete this and every remaining unmerged branch
+		for _, rest := range m.unmerged[m.unmergedIndex:] {
+			m.forceDelete[rest.Name] = true
+		}
+		m.unmergedIndex = len(m.unmerged) - 1
+	case "n", "s":
+		// Keep the branch: take it out of the run
 		for _, b := range m.branches {
-			b.Selected = false
+			if b.Name == u.Name {
+				b.Selected = false
+			}
 		}
+	case "q", "ctrl+c", "esc":
+		m.cancelDelete()
 		return m, nil
 	default:
-		// Any other key is ignored
 		return m, nil
 	}
 
-	// This part is reached only if '1' or '2' was pressed
+	m.unmergedIndex++
+	if m.unmergedIndex < len(m.unmerged) {
+		return m, nil
+	}
+	for _, b := range m.branches {
+		if b.Selected {
+			return m.startDeleteRun()
+		}
+	}
+	m.cancelDelete()
+	m.message = "Nothing left to delete"
+	return m, nil
+}
+
+// forceDeletes reports whether a branch is deleted with 'git branch -D': its
+// changes are on its base branch, or it was confirmed despite being unmerged
+func (m Model) forceDeletes(b *Branch) bool {
+	return (b.Cleanup != nil && b.Cleanup.ForceDelete()) || m.forceDelete[b.Name]
+}
+
+// startDeleteRun deletes (or archives) the selected branches
+func (m Model) startDeleteRun() (tea.Model, tea.Cmd) {
+	m.beginRun()
+	m.message = ""
 	m.state = stateDeleting
 	m.updateIndex = 0
 	m.successCount = 0
@@ -657,10 +1345,22 @@ func (m Model) handleConfirmingDeleteTypeKeys(msg tea.KeyMsg) (tea.Model, tea.Cm
 	// Populate command log for deletion
 	m.commandLog = []string{}
 	for _, b := range m.branches {
-		if b.Selected {
-			m.commandLog = append(m.commandLog, fmt.Sprintf("git branch -d %s", b.Name))
+		if b.Selected && m.archive {
+			remote := m.config.PushRemote(b.Name)
+			m.commandLog = append(m.commandLog, fmt.Sprintf("git update-ref %s%s %s", archiveRefPrefix, b.Name, b.Name))
+			m.commandLog = append(m.commandLog, fmt.Sprintf("git branch -D %s", b.Name))
+			if m.deleteRemote {
+				m.commandLog = append(m.commandLog, fmt.Sprintf("git push %s %s%s", remote, archiveRefPrefix, b.Name))
+				m.commandLog = append(m.commandLog, fmt.Sprintf("git push %s --delete %s", remote, b.Name))
+			}
+		} else if b.Selected {
+			flag := "-d"
+			if m.forceDeletes(b) {
+				flag = "-D"
+			}
+			m.commandLog = append(m.commandLog, fmt.Sprintf("git branch %s %s", flag, b.Name))
 			if m.deleteRemote {
-				m.commandLog = append(m.commandLog, fmt.Sprintf("git push origin --delete %s", b.Name))
+				m.commandLog = append(m.commandLog, fmt.Sprintf("git push %s --delete %s", m.config.PushRemote(b.Name), b.Name))
 			}
 		}
 	}
@@ -672,26 +1372,13 @@ func (m Model) handleConfirmingDeleteTypeKeys(msg tea.KeyMsg) (tea.Model, tea.Cm
 func (m Model) handleConfirmingStashKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "y", "Y":
-		if err := StashChanges(); err != nil {
+		m.beginRun()
+		if err := m.restore.Stash(); err != nil {
 			m.state = stateError
 			m.error = err.Error()
+			m.teardown()
 			return m, nil
 		}
-		m.didStash = true
-
-		// Populate command log
-		m.commandLog = []string{}
-		m.commandLog = append(m.commandLog, fmt.Sprintf("git fetch %s %s", m.config.UpstreamRemote, m.config.BaseBranch))
-		m.commandLog = append(m.commandLog, fmt.Sprintf("git checkout %s", m.config.BaseBranch))
-		m.commandLog = append(m.commandLog, fmt.Sprintf("git reset --hard %s/%s", m.config.UpstreamRemote, m.config.BaseBranch))
-		m.commandLog = append(m.commandLog, fmt.Sprintf("git push origin %s --force-with-lease", m.config.BaseBranch))
-		for _, b := range m.branches {
-			if b.Selected {
-				m.commandLog = append(m.commandLog, fmt.Sprintf("git checkout %s", b.Name))
-				m.commandLog = append(m.commandLog, fmt.Sprintf("git rebase %s", m.config.BaseBranch))
-				m.commandLog = append(m.commandLog, fmt.Sprintf("git push origin %s --force-with-lease", b.Name))
-			}
-		}
 
 		// Proceed with update
 		selectedCount := 0
@@ -703,18 +1390,21 @@ func (m Model) handleConfirmingStashKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if selectedCount == 0 {
 			m.message = "No branches selected"
 			m.state = stateBrowsing
+			m.cancelRun()
 			return m, nil
 		}
-		if manualMode {
+		if m.config.ManualMode {
 			m.state = stateConfirming
 			m.message = fmt.Sprintf("Ready to update %d branch(es). Press 'y' to continue, 'n' to cancel.", selectedCount)
 		} else {
+			m.beginRun()
 			m.state = stateUpdating
 			m.updateIndex = 0
 			m.successCount = 0
 			m.failedBranches = []string{}
 			m.selectedForActionCount = selectedCount
-			return m, m.updateNextBranch()
+			m.commandLog = SyncCommands(m.config, m.selectedBranches())
+			return m, m.prepareBases()
 		}
 
 	case "n", "N", "q", "ctrl+c", "esc":
@@ -762,6 +1452,72 @@ func (m Model) handleTaggingKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// handleEditingBaseKeys handles keys while editing a branch's base branch
+func (m Model) handleEditingBaseKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		var err error
+		if m.baseInput != "" {
+			err = SetBranchBase(m.baseBranch, m.baseInput)
+		} else if GetBranchBase(m.baseBranch) != "" {
+			err = RemoveBranchBase(m.baseBranch)
+		}
+		m.state = stateBrowsing
+		if err != nil {
+			m.message = fmt.Sprintf("Failed to set the base of %s: %v", m.baseBranch, err)
+			return m, nil
+		}
+		m.message = fmt.Sprintf("Fetching the base of %s...", m.baseBranch)
+		return m, m.reloadBranch(m.baseBranch)
+
+	case "esc", "ctrl+c":
+		m.state = stateBrowsing
+
+	case "backspace":
+		if len(m.baseInput) > 0 {
+			m.baseInput = m.baseInput[:len(m.baseInput)-1]
+		}
+
+	default:
+		if len(msg.String()) == 1 {
+			m.baseInput += msg.String()
+		}
+	}
+
+	return m, nil
+}
+
+// reloadBranch fetches a branch's base branch and reloads its info
+func (m Model) reloadBranch(name string) tea.Cmd {
+	config := m.config
+	return func() (msg tea.Msg) {
+		defer recoverAsError(&msg)
+		base, _ := config.ResolveBase(name)
+		err := FetchUpstream(config.UpstreamRemote, base)
+		branch, _ := GetBranchInfo(name, config)
+		return branchReloadedMsg{branch: branch, err: err}
+	}
+}
+
+// cycleStrategy sets a branch's strategy to the next one in Strategies. After
+// skip, the branch's own setting is removed so the config patterns apply again.
+func cycleStrategy(config *Config, branch *Branch) error {
+	var err error
+	if branch.Strategy == StrategySkip && GetBranchStrategy(branch.Name) != "" {
+		err = RemoveBranchStrategy(branch.Name)
+	} else {
+		next := Strategies[0]
+		for i, s := range Strategies {
+			if s == branch.Strategy && i+1 < len(Strategies) {
+				next = Strategies[i+1]
+			}
+		}
+		err = SetBranchStrategy(branch.Name, next)
+	}
+	branch.Strategy, branch.StrategySource = config.ResolveStrategy(branch.Name)
+	return err
+}
+
 // handleHelpKeys handles keys in help state
 func (m Model) handleHelpKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
@@ -772,143 +1528,897 @@ func (m Model) handleHelpKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-// --- Checkout Mode ---
+// --- Run Lifecycle ---
 
-func doCheckout(branchName string) tea.Cmd {
-	return func() tea.Msg {
-		return checkoutMsg{err: CheckoutBranch(branchName)}
+// beginRun records where to return to once the run is over
+func (m *Model) beginRun() {
+	if m.restore == nil {
+		m.restore = CaptureRestorePoint()
 	}
+	m.restoreErr = ""
+	m.cancelling = false
+	m.backup = NewBackup()
+	m.stacked = nil
+	m.failedNames = map[string]bool{}
+	m.skippedBranches = nil
+	m.baseErrs = nil
+	m.deletedSHAs = map[string]string{}
 }
 
-func doCreateAndCheckout(newBranchName, fromBranch string) tea.Cmd {
-	return func() tea.Msg {
-		return checkoutMsg{err: CreateAndCheckoutBranch(newBranchName, fromBranch)}
+// teardown returns to the original branch (or detached HEAD) and then restores
+// stashed changes. It runs after every run, including failed and cancelled ones.
+func (m *Model) teardown() {
+	if m.conflict != nil {
+		m.conflict.Abort()
+		m.conflict = nil
+	}
+	if err := m.restore.Restore(); err != nil {
+		m.restoreErr = err.Error()
 	}
 }
 
-func (m Model) getFilteredCheckoutBranches() []string {
-	if m.checkoutSearchQuery == "" {
-		return m.allBranches
+// cancelRun tears down a run that was cancelled before it started
+func (m *Model) cancelRun() {
+	m.teardown()
+	if m.restoreErr != "" {
+		m.message = m.restoreErr
 	}
+	m.restore = nil
+}
 
-	var filtered []string
-	query := strings.ToLower(m.checkoutSearchQuery)
+// finishRun ends a sync or delete run, quitting if it was cancelled with ctrl+c
+func (m Model) finishRun() (tea.Model, tea.Cmd) {
+	m.state = stateDone
+	m.teardown()
+	if m.cancelling {
+		return m, tea.Quit
+	}
+	return m, nil
+}
 
-	for _, branchName := range m.allBranches {
-		if strings.Contains(strings.ToLower(branchName), query) {
-			filtered = append(filtered, branchName)
-		}
+// recoverAsError turns a panic inside a command into an errorMsg, so the run is still torn down
+func recoverAsError(msg *tea.Msg) {
+	if r := recover(); r != nil {
+		*msg = errorMsg{fmt.Errorf("unexpected error: %v", r)}
 	}
-	return filtered
 }
 
-func (m Model) handleCheckoutListKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	filtered := m.getFilteredCheckoutBranches()
-	// The real list count is the filtered list + 1 for the "[ Create new branch ]" item
-	realListCount := len(filtered) + 1
+// --- End Run Lifecycle ---
 
-	switch key := msg.String(); key {
-	case "ctrl+c", "q", "esc":
-		m.state = stateBrowsing
-		m.message = ""
-		return m, nil
+// --- Conflict Resolution ---
 
-	case "up", "k":
-		if m.checkoutCursor > 0 {
-			m.checkoutCursor--
-		}
+// handleConflictKeys handles keys while a rebase is paused on a conflict
+func (m Model) handleConflictKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "e":
+		return m, tea.ExecProcess(editorCommand(m.conflict.Dir, m.conflictInfo.Files), func(err error) tea.Msg {
+			return conflictToolDoneMsg{err}
+		})
+
+	case "m":
+		cmd := exec.Command("git", "mergetool")
+		cmd.Dir = m.conflict.Dir
+		return m, tea.ExecProcess(cmd, func(err error) tea.Msg {
+			return conflictToolDoneMsg{err}
+		})
 
-	case "down", "j":
-		if m.checkoutCursor < realListCount-1 {
-			m.checkoutCursor++
-		}
+	case "c":
+		m.state = stateUpdating
+		return m, m.resumeConflict((*Rebase).Continue)
 
-	case "n":
-		m.state = stateCheckoutNew
-		m.newBranchNameInput = ""
-		m.newBranchFromInput = ""
-		return m, nil
+	case "s":
+		m.state = stateUpdating
+		return m, m.resumeConflict((*Rebase).Skip)
 
-	case "/":
-		m.searchMode = true
-		m.checkoutSearchQuery = ""
-		m.checkoutCursor = 0
-		return m, nil
+	case "a":
+		m.state = stateUpdating
+		return m, m.abortConflict()
 
-	case "enter":
-		// Cursor is on "[ Create new branch ]"
-		if m.checkoutCursor == 0 {
-			m.state = stateCheckoutNew
-			m.newBranchNameInput = ""
-			m.newBranchFromInput = ""
-			return m, nil
-		}
-		// Cursor is on an existing branch
-		branchToCheckout := filtered[m.checkoutCursor-1]
-		return m, doCheckout(branchToCheckout)
+	case "ctrl+c":
+		// Abort this branch and stop the run
+		m.cancelling = true
+		m.state = stateUpdating
+		return m, m.abortConflict()
+	}
 
-	default:
-		// Handle search typing
-		if m.searchMode {
-			if key == "backspace" {
-				if len(m.checkoutSearchQuery) > 0 {
-					m.checkoutSearchQuery = m.checkoutSearchQuery[:len(m.checkoutSearchQuery)-1]
-					m.checkoutCursor = 0
-				}
-			} else if len(key) == 1 {
-				m.checkoutSearchQuery += key
-				m.checkoutCursor = 0
-			}
+	return m, nil
+}
+
+// resumeConflict continues or skips past the conflict, pausing again if the
+// next commit conflicts too
+func (m Model) resumeConflict(step func(*Rebase) error) tea.Cmd {
+	r := m.conflict
+	return func() (msg tea.Msg) {
+		defer recoverAsError(&msg)
+
+		err := step(r)
+		if err != nil && !errors.Is(err, ErrRebaseConflict) && !errors.Is(err, ErrMergeConflict) {
+			// Nothing happened (e.g. markers left in a file); stay paused
+			info, _ := r.Conflict()
+			return conflictMsg{rebase: r, info: info, note: err.Error()}
 		}
+		return m.afterRebaseStep(r, err)
 	}
+}
 
-	// If searching, exit search mode on navigation or enter
-	if m.searchMode && (msg.Type == tea.KeyUp || msg.Type == tea.KeyDown || msg.Type == tea.KeyEnter) {
-		m.searchMode = false
+// abortConflict abandons the paused rebase (or merge) and marks the branch as failed
+func (m Model) abortConflict() tea.Cmd {
+	r := m.conflict
+	return func() tea.Msg {
+		r.Abort()
+		if r.Strategy == StrategyMerge {
+			return branchUpdatedMsg{branch: r.Branch, success: false, error: "merge conflict (aborted)"}
+		}
+		return branchUpdatedMsg{branch: r.Branch, success: false, error: "rebase conflict (aborted)"}
 	}
+}
 
-	return m, nil
+// editorCommand opens the conflicted files in $VISUAL or $EDITOR
+func editorCommand(dir string, files []string) *exec.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := append(strings.Fields(editor), files...)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	return cmd
 }
 
-func (m Model) handleCheckoutNewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch key := msg.String(); key {
-	case "ctrl+c", "esc":
-		m.state = stateCheckoutList
-		return m, nil
+// --- End Conflict Resolution ---
 
-	case "enter":
-		if m.newBranchNameInput != "" {
-			m.state = stateCheckoutNewFrom
-		}
+// --- Dry Run ---
+
+// previewSync computes what syncing the selected branches would do
+func (m Model) previewSync() (tea.Model, tea.Cmd) {
+	m.selectStacks()
+	targets := m.selectedBranches()
+	if len(targets) == 0 {
+		m.message = "No branches selected"
 		return m, nil
+	}
+	m.state = stateLoading
+	m.message = fmt.Sprintf("Planning the sync of %d branch(es)...", len(targets))
+	config := m.config
+	return m, func() (msg tea.Msg) {
+		defer recoverAsError(&msg)
+		plan, err := PlanSync(config, targets)
+		return planMsg{plan: plan, err: err}
+	}
+}
 
-	case "backspace":
-		if len(m.newBranchNameInput) > 0 {
-			m.newBranchNameInput = m.newBranchNameInput[:len(m.newBranchNameInput)-1]
-		}
+// handlePlanKeys handles keys while the dry-run plan is shown
+func (m Model) handlePlanKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		return m.startSync()
+	case "ctrl+c", "q", "esc":
+		m.state = stateBrowsing
+		m.plan = nil
+	}
+	return m, nil
+}
 
-	default:
-		if len(key) == 1 {
-			m.newBranchNameInput += key
+// branchPlan returns the dry-run plan for a branch, if it was previewed
+func (m Model) branchPlan(name string) *BranchPlan {
+	if m.plan == nil {
+		return nil
+	}
+	for i := range m.plan.Branches {
+		if m.plan.Branches[i].Name == name {
+			return &m.plan.Branches[i]
 		}
 	}
-	return m, nil
+	return nil
 }
 
-func (m Model) handleCheckoutNewFromKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch key := msg.String(); key {
-	case "ctrl+c", "esc":
-		m.state = stateCheckoutNew
-		return m, nil
+// --- End Dry Run ---
 
-	case "enter":
-		fromBranch := m.newBranchFromInput
-		if fromBranch == "" {
-			fromBranch = m.config.BaseBranch
-		}
-		return m, doCreateAndCheckout(m.newBranchNameInput, fromBranch)
+// --- Cleanup ---
 
-	case "backspace":
+// openCleanup classifies the branches and shows the ones that look safe to delete
+func (m Model) openCleanup() (tea.Model, tea.Cmd) {
+	m.state = stateCleanup
+	m.cleanupLoading = true
+	m.cleanupCursor = 0
+	for _, b := range m.branches {
+		b.Selected = false
+		b.Cleanup = nil
+	}
+	config, branches := m.config, m.branches
+	return m, func() (msg tea.Msg) {
+		defer recoverAsError(&msg)
+		candidates, err := FindCleanupCandidates(config, branches)
+		return cleanupFoundMsg{candidates: candidates, err: err}
+	}
+}
+
+// cleanupBranches returns the branches the cleanup view lists
+func (m Model) cleanupBranches() []*Branch {
+	var branches []*Branch
+	for _, b := range m.branches {
+		if b.Cleanup != nil {
+			branches = append(branches, b)
+		}
+	}
+	return branches
+}
+
+// handleCleanupKeys handles keys in the cleanup view
+func (m Model) handleCleanupKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	branches := m.cleanupBranches()
+	switch msg.String() {
+	case "ctrl+c", "q", "esc":
+		for _, b := range m.branches {
+			b.Selected = false
+			b.Cleanup = nil
+		}
+		m.state = stateBrowsing
+		m.cleanupLoading = false
+		return m, nil
+
+	case "up", "k":
+		if m.cleanupCursor > 0 {
+			m.cleanupCursor--
+		}
+
+	case "down", "j":
+		if m.cleanupCursor < len(branches)-1 {
+			m.cleanupCursor++
+		}
+
+	case " ":
+		if m.cleanupCursor < len(branches) {
+			branches[m.cleanupCursor].Selected = !branches[m.cleanupCursor].Selected
+		}
+
+	case "a":
+		for _, b := range branches {
+			b.Selected = true
+		}
+
+	case "n":
+		for _, b := range branches {
+			b.Selected = false
+		}
+
+	case "d", "enter":
+		selectedCount := 0
+		for _, b := range branches {
+			if b.Selected {
+				selectedCount++
+			}
+		}
+		if selectedCount > 0 {
+			m.deleteMode = true
+			m.state = stateConfirmingDelete
+			m.message = fmt.Sprintf("Ready to delete %d branch(es).", selectedCount)
+		}
+	}
+
+	return m, nil
+}
+
+// --- End Cleanup ---
+
+// --- Log Viewer ---
+
+// openLog shows the log of a branch, its own commits first, or with diff set its full diff
+func (m Model) openLog(branch *Branch, diff bool) (tea.Model, tea.Cmd) {
+	m.state = stateLog
+	m.logBranch = branch
+	m.logFull = false
+	m.logDiff = diff
+	m.logSearching = false
+	m.logQuery = ""
+	return m.loadLog()
+}
+
+// loadLog loads the log of m.logBranch in the current mode
+func (m Model) loadLog() (tea.Model, tea.Cmd) {
+	m.logLoading = true
+	m.logLines = nil
+	m.logErr = ""
+	m.logOffset = 0
+	m.logMatches = nil
+	config, branch, full, diff := m.config, m.logBranch, m.logFull, m.logDiff
+	return m, func() (msg tea.Msg) {
+		defer recoverAsError(&msg)
+		var lines []string
+		var err error
+		if diff {
+			lines, err = BranchDiff(config, branch)
+		} else {
+			lines, err = BranchLog(config, branch, full)
+		}
+		return logLoadedMsg{branch: branch.Name, full: full, diff: diff, lines: lines, err: err}
+	}
+}
+
+// logPageSize returns how many log lines fit on the screen
+func (m Model) logPageSize() int {
+	if m.height == 0 {
+		return 20
+	}
+	return max(m.height-8, 5)
+}
+
+// scrollLog moves the log by delta lines, keeping it on the screen
+func (m *Model) scrollLog(delta int) {
+	m.logOffset = max(min(m.logOffset+delta, len(m.logLines)-m.logPageSize()), 0)
+}
+
+// findLogMatches finds the lines matching the search query and jumps to the first one
+func (m *Model) findLogMatches() {
+	m.logMatches = nil
+	m.logMatch = 0
+	if m.logQuery == "" {
+		return
+	}
+	query := strings.ToLower(m.logQuery)
+	for i, line := range m.logLines {
+		if strings.Contains(strings.ToLower(stripANSI(line)), query) {
+			m.logMatches = append(m.logMatches, i)
+		}
+	}
+	m.showLogMatch()
+}
+
+// showLogMatch scrolls the current match into view
+func (m *Model) showLogMatch() {
+	if m.logMatch >= len(m.logMatches) {
+		return
+	}
+	line := m.logMatches[m.logMatch]
+	if line < m.logOffset || line >= m.logOffset+m.logPageSize() {
+		m.logOffset = 0
+		m.scrollLog(line - m.logPageSize()/2)
+	}
+}
+
+// handleLogKeys handles keys in the log viewer
+func (m Model) handleLogKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.logSearching {
+		switch msg.Type {
+		case tea.KeyEnter:
+			m.logSearching = false
+		case tea.KeyEsc:
+			m.logSearching = false
+			m.logQuery = ""
+		case tea.KeyBackspace:
+			if len(m.logQuery) > 0 {
+				m.logQuery = m.logQuery[:len(m.logQuery)-1]
+			}
+		case tea.KeyRunes, tea.KeySpace:
+			m.logQuery += string(msg.Runes)
+		}
+		m.findLogMatches()
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "ctrl+c", "q":
+		m.state = stateBrowsing
+		m.logBranch = nil
+		return m, nil
+
+	case "esc":
+		if m.logQuery != "" {
+			m.logQuery = ""
+			m.findLogMatches()
+			return m, nil
+		}
+		m.state = stateBrowsing
+		m.logBranch = nil
+		return m, nil
+
+	case "up", "k":
+		m.scrollLog(-1)
+
+	case "down", "j":
+		m.scrollLog(1)
+
+	case "pgup", "b":
+		m.scrollLog(-m.logPageSize())
+
+	case "pgdown", " ":
+		m.scrollLog(m.logPageSize())
+
+	case "home", "g":
+		m.logOffset = 0
+
+	case "end", "G":
+		m.scrollLog(len(m.logLines))
+
+	case "a":
+		// Toggle between the branch's own commits and its whole history
+		if m.logDiff {
+			return m, nil
+		}
+		m.logFull = !m.logFull
+		return m.loadLog()
+
+	case "d":
+		// Switch between the log and the diff
+		m.logDiff = !m.logDiff
+		m.logFull = false
+		return m.loadLog()
+
+	case "/":
+		m.logSearching = true
+		m.logQuery = ""
+		m.findLogMatches()
+
+	case "n":
+		if len(m.logMatches) > 0 {
+			m.logMatch = (m.logMatch + 1) % len(m.logMatches)
+			m.showLogMatch()
+		}
+
+	case "N":
+		if len(m.logMatches) > 0 {
+			m.logMatch = (m.logMatch + len(m.logMatches) - 1) % len(m.logMatches)
+			m.showLogMatch()
+		}
+	}
+
+	return m, nil
+}
+
+// --- End Log Viewer ---
+
+// --- Details Panel ---
+
+// showDetails reports whether the details panel fits next to the branch list
+func (m Model) showDetails() bool {
+	return !m.detailsHidden && m.width >= detailsMinWidth
+}
+
+// loadDetails loads the details of the branch under the cursor, unless they
+// are loaded, being loaded, or not shown
+func (m Model) loadDetails() tea.Cmd {
+	filtered := m.getFilteredBranches()
+	if !m.showDetails() || m.cursor >= len(filtered) {
+		return nil
+	}
+	branch := filtered[m.cursor]
+	if m.details[branch.Name] != nil || m.detailsPending[branch.Name] {
+		return nil
+	}
+	m.detailsPending[branch.Name] = true
+	config := m.config
+	return func() (msg tea.Msg) {
+		defer recoverAsError(&msg)
+		return detailsLoadedMsg{branch: branch.Name, details: LoadBranchDetails(config, branch)}
+	}
+}
+
+// --- End Details Panel ---
+
+// --- Remote Branches ---
+
+// openRemoteBranches lists the branches on the default push remote
+func (m Model) openRemoteBranches() (tea.Model, tea.Cmd) {
+	m.state = stateRemoteBranches
+	m.remote = m.config.DefaultPushRemote()
+	m.remoteBranches = nil
+	m.remoteSelected = map[string]bool{}
+	m.remoteCursor = 0
+	m.remoteLoading = true
+	m.remoteNote = ""
+	m.remoteChanged = false
+	config, remote := m.config, m.remote
+	return m, func() (msg tea.Msg) {
+		defer recoverAsError(&msg)
+		return loadRemoteBranches(config, remote)
+	}
+}
+
+// loadRemoteBranches lists the branches on remote
+func loadRemoteBranches(config *Config, remote string) tea.Msg {
+	branches, err := ListRemoteBranches(config, remote)
+	return remoteBranchesMsg{branches: branches, err: err}
+}
+
+// selectedRemoteBranches returns the remote branches selected for deletion
+func (m Model) selectedRemoteBranches() []*RemoteBranch {
+	var selected []*RemoteBranch
+	for _, b := range m.remoteBranches {
+		if m.remoteSelected[b.Name] {
+			selected = append(selected, b)
+		}
+	}
+	return selected
+}
+
+// handleRemoteBranchesKeys handles keys in the remote branches view
+func (m Model) handleRemoteBranchesKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q", "esc":
+		if m.remoteChanged {
+			m.state = stateLoading
+			m.message = "Refreshing repository information after deleting remote branches..."
+			return m, loadRepoInfo
+		}
+		m.state = stateBrowsing
+		return m, nil
+
+	case "up", "k":
+		if m.remoteCursor > 0 {
+			m.remoteCursor--
+		}
+
+	case "down", "j":
+		if m.remoteCursor < len(m.remoteBranches)-1 {
+			m.remoteCursor++
+		}
+
+	case " ":
+		if m.remoteCursor < len(m.remoteBranches) {
+			name := m.remoteBranches[m.remoteCursor].Name
+			m.remoteSelected[name] = !m.remoteSelected[name]
+		}
+
+	case "a":
+		for _, b := range m.remoteBranches {
+			m.remoteSelected[b.Name] = true
+		}
+
+	case "m":
+		// Select the merged branches and the ones already gone from the remote
+		for _, b := range m.remoteBranches {
+			if b.Merged || b.Gone {
+				m.remoteSelected[b.Name] = true
+			}
+		}
+
+	case "n":
+		m.remoteSelected = map[string]bool{}
+
+	case "d", "enter":
+		if len(m.selectedRemoteBranches()) > 0 {
+			m.state = stateConfirmingRemoteDelete
+		}
+	}
+
+	return m, nil
+}
+
+// handleConfirmingRemoteDeleteKeys handles keys on the remote delete confirmation screen
+func (m Model) handleConfirmingRemoteDeleteKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "y", "enter":
+		selected, remote := m.selectedRemoteBranches(), m.remote
+		m.state = stateRemoteBranches
+		m.remoteNote = fmt.Sprintf("Deleting %d branch(es) on %s...", len(selected), remote)
+		return m, func() (msg tea.Msg) {
+			defer recoverAsError(&msg)
+			deleted, err := DeleteRemoteBranches(remote, selected, NewBackup())
+			return remoteDeletedMsg{deleted: deleted, err: err}
+		}
+
+	case "n", "esc", "ctrl+c", "q":
+		m.state = stateRemoteBranches
+	}
+
+	return m, nil
+}
+
+// --- End Remote Branches ---
+
+// --- Archive ---
+
+// openArchived shows the list of archived branches
+func (m Model) openArchived() (tea.Model, tea.Cmd) {
+	m.state = stateArchived
+	m.archived = nil
+	m.archivedCursor = 0
+	m.archivedNote = ""
+	m.archivedChanged = false
+	return m, loadArchived
+}
+
+// loadArchived lists the archived branches
+func loadArchived() tea.Msg {
+	archived, err := ListArchivedBranches()
+	return archivedListMsg{archived: archived, err: err}
+}
+
+// handleArchivedKeys handles keys in the archived branches screen
+func (m Model) handleArchivedKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q", "esc":
+		if m.archivedChanged {
+			m.state = stateLoading
+			m.message = "Refreshing repository information after restoring..."
+			return m, loadRepoInfo
+		}
+		m.state = stateBrowsing
+		return m, nil
+
+	case "up", "k":
+		if m.archivedCursor > 0 {
+			m.archivedCursor--
+		}
+
+	case "down", "j":
+		if m.archivedCursor < len(m.archived)-1 {
+			m.archivedCursor++
+		}
+
+	case "enter", "r":
+		if m.archivedCursor >= len(m.archived) {
+			return m, nil
+		}
+		a := m.archived[m.archivedCursor]
+		m.archivedNote = "Restoring " + a.Name + "..."
+		return m, func() (msg tea.Msg) {
+			defer recoverAsError(&msg)
+			return archiveRestoredMsg{name: a.Name, err: RestoreArchivedBranch(a)}
+		}
+	}
+
+	return m, nil
+}
+
+// --- End Archive ---
+
+// --- Trash ---
+
+// openTrash shows the deleted branches in the trash
+func (m Model) openTrash() (tea.Model, tea.Cmd) {
+	m.state = stateTrash
+	m.trash = nil
+	m.trashCursor = 0
+	m.trashNote = ""
+	m.trashChanged = false
+	return m, loadTrash
+}
+
+// loadTrash lists the trash
+func loadTrash() tea.Msg {
+	entries, err := ListTrash()
+	return trashListMsg{entries: entries, err: err}
+}
+
+// handleTrashKeys handles keys in the trash screen
+func (m Model) handleTrashKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q", "esc":
+		if m.trashChanged {
+			m.state = stateLoading
+			m.message = "Refreshing repository information after restoring..."
+			return m, loadRepoInfo
+		}
+		m.state = stateBrowsing
+		return m, nil
+
+	case "up", "k":
+		if m.trashCursor > 0 {
+			m.trashCursor--
+		}
+
+	case "down", "j":
+		if m.trashCursor < len(m.trash)-1 {
+			m.trashCursor++
+		}
+
+	case "enter", "r":
+		if m.trashCursor >= len(m.trash) {
+			return m, nil
+		}
+		e := m.trash[m.trashCursor]
+		m.trashNote = "Restoring " + e.Name + "..."
+		return m, func() (msg tea.Msg) {
+			defer recoverAsError(&msg)
+			return trashRestoredMsg{name: e.Name, err: RestoreTrash(e)}
+		}
+	}
+
+	return m, nil
+}
+
+// --- End Trash ---
+
+// --- Undo ---
+
+// openUndo shows the list of backups
+func (m Model) openUndo() (tea.Model, tea.Cmd) {
+	m.state = stateUndo
+	m.undoRuns = nil
+	m.undoCursor = 0
+	m.undoNote = ""
+	m.undoChanged = false
+	return m, loadBackups
+}
+
+// loadBackups lists the backup runs
+func loadBackups() tea.Msg {
+	runs, err := ListBackups()
+	return undoListMsg{runs: runs, err: err}
+}
+
+// handleUndoKeys handles keys in the undo screen
+func (m Model) handleUndoKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q", "esc":
+		if m.undoChanged {
+			m.state = stateLoading
+			m.message = "Refreshing repository information after undo..."
+			return m, loadRepoInfo
+		}
+		m.state = stateBrowsing
+		return m, nil
+
+	case "up", "k":
+		if m.undoCursor > 0 {
+			m.undoCursor--
+		}
+
+	case "down", "j":
+		if m.undoCursor < len(m.undoRuns)-1 {
+			m.undoCursor++
+		}
+
+	case "enter", "r":
+		if m.undoCursor >= len(m.undoRuns) {
+			return m, nil
+		}
+		run := m.undoRuns[m.undoCursor]
+		remote := msg.String() == "r"
+		m.undoNote = "Restoring " + run.ID + "..."
+		return m, func() (msg tea.Msg) {
+			defer recoverAsError(&msg)
+			restored, err := RestoreBackup(&run, remote)
+			return undoDoneMsg{restored: restored, err: err}
+		}
+	}
+
+	return m, nil
+}
+
+// --- End Undo ---
+
+// --- Checkout Mode ---
+
+func doCheckout(branchName string) tea.Cmd {
+	return func() tea.Msg {
+		return checkoutMsg{err: CheckoutBranch(branchName)}
+	}
+}
+
+func doCreateAndCheckout(newBranchName, fromBranch string) tea.Cmd {
+	return func() tea.Msg {
+		return checkoutMsg{err: CreateAndCheckoutBranch(newBranchName, fromBranch)}
+	}
+}
+
+func (m Model) getFilteredCheckoutBranches() []string {
+	if m.checkoutSearchQuery == "" {
+		return m.allBranches
+	}
+
+	var filtered []string
+	query := strings.ToLower(m.checkoutSearchQuery)
+
+	for _, branchName := range m.allBranches {
+		if strings.Contains(strings.ToLower(branchName), query) {
+			filtered = append(filtered, branchName)
+		}
+	}
+	return filtered
+}
+
+func (m Model) handleCheckoutListKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	filtered := m.getFilteredCheckoutBranches()
+	// The real list count is the filtered list + 1 for the "[ Create new branch ]" item
+	realListCount := len(filtered) + 1
+
+	switch key := msg.String(); key {
+	case "ctrl+c", "q", "esc":
+		m.state = stateBrowsing
+		m.message = ""
+		return m, nil
+
+	case "up", "k":
+		if m.checkoutCursor > 0 {
+			m.checkoutCursor--
+		}
+
+	case "down", "j":
+		if m.checkoutCursor < realListCount-1 {
+			m.checkoutCursor++
+		}
+
+	case "n":
+		m.state = stateCheckoutNew
+		m.newBranchNameInput = ""
+		m.newBranchFromInput = ""
+		return m, nil
+
+	case "/":
+		m.searchMode = true
+		m.checkoutSearchQuery = ""
+		m.checkoutCursor = 0
+		return m, nil
+
+	case "enter":
+		// Cursor is on "[ Create new branch ]"
+		if m.checkoutCursor == 0 {
+			m.state = stateCheckoutNew
+			m.newBranchNameInput = ""
+			m.newBranchFromInput = ""
+			return m, nil
+		}
+		// Cursor is on an existing branch
+		branchToCheckout := filtered[m.checkoutCursor-1]
+		return m, doCheckout(branchToCheckout)
+
+	default:
+		// Handle search typing
+		if m.searchMode {
+			if key == "backspace" {
+				if len(m.checkoutSearchQuery) > 0 {
+					m.checkoutSearchQuery = m.checkoutSearchQuery[:len(m.checkoutSearchQuery)-1]
+					m.checkoutCursor = 0
+				}
+			} else if len(key) == 1 {
+				m.checkoutSearchQuery += key
+				m.checkoutCursor = 0
+			}
+		}
+	}
+
+	// If searching, exit search mode on navigation or enter
+	if m.searchMode && (msg.Type == tea.KeyUp || msg.Type == tea.KeyDown || msg.Type == tea.KeyEnter) {
+		m.searchMode = false
+	}
+
+	return m, nil
+}
+
+func (m Model) handleCheckoutNewKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch key := msg.String(); key {
+	case "ctrl+c", "esc":
+		m.state = stateCheckoutList
+		return m, nil
+
+	case "enter":
+		if m.newBranchNameInput != "" {
+			m.state = stateCheckoutNewFrom
+		}
+		return m, nil
+
+	case "backspace":
+		if len(m.newBranchNameInput) > 0 {
+			m.newBranchNameInput = m.newBranchNameInput[:len(m.newBranchNameInput)-1]
+		}
+
+	default:
+		if len(key) == 1 {
+			m.newBranchNameInput += key
+		}
+	}
+	return m, nil
+}
+
+func (m Model) handleCheckoutNewFromKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch key := msg.String(); key {
+	case "ctrl+c", "esc":
+		m.state = stateCheckoutNew
+		return m, nil
+
+	case "enter":
+		fromBranch := m.newBranchFromInput
+		if fromBranch == "" {
+			fromBranch = m.config.BaseBranch
+		}
+		return m, doCreateAndCheckout(m.newBranchNameInput, fromBranch)
+
+	case "backspace":
 		if len(m.newBranchFromInput) > 0 {
 			m.newBranchFromInput = m.newBranchFromInput[:len(m.newBranchFromInput)-1]
 		}
@@ -923,9 +2433,27 @@ func (m Model) handleCheckoutNewFromKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 // --- End Checkout Mode ---
 
+// prepareBases fetches and updates every base branch the selected branches are synced onto, once per run
+func (m Model) prepareBases() tea.Cmd {
+	bases := RunBases(m.config, m.selectedBranches())
+	config, backup := m.config, m.backup
+	return func() (msg tea.Msg) {
+		defer recoverAsError(&msg)
+		errs := map[string]error{}
+		for _, base := range bases {
+			if err := PrepareBase(config, base, backup); err != nil {
+				errs[base] = err
+			}
+		}
+		return basesPreparedMsg{errs: errs}
+	}
+}
+
 // updateNextBranch updates the next selected branch
 func (m Model) updateNextBranch() tea.Cmd {
-	return func() tea.Msg {
+	return func() (msg tea.Msg) {
+		defer recoverAsError(&msg)
+
 		// Find next selected branch
 		var targetBranch *Branch
 		currentIndex := 0
@@ -943,34 +2471,86 @@ func (m Model) updateNextBranch() tea.Cmd {
 			return branchUpdatedMsg{success: false, error: "branch not found"}
 		}
 
-		// Update base branch first (only on first iteration)
-		if m.updateIndex == 0 {
-			if err := FetchUpstream(m.config.UpstreamRemote, m.config.BaseBranch); err != nil {
-				return branchUpdatedMsg{branch: targetBranch.Name, success: false, error: "fetch failed"}
-			}
+		// Branches set to skip are left alone
+		if targetBranch.Strategy == StrategySkip {
+			return branchUpdatedMsg{branch: targetBranch.Name, success: true, skipped: true}
+		}
 
-			if err := UpdateBaseBranch(m.config.BaseBranch, m.config.UpstreamRemote); err != nil {
-				return branchUpdatedMsg{branch: targetBranch.Name, success: false, error: err.Error()}
-			}
+		// A branch can't be synced onto a base branch that could not be updated
+		if err := m.baseErrs[targetBranch.Base]; err != nil && targetBranch.Parent == "" {
+			return branchUpdatedMsg{branch: targetBranch.Name, success: false, error: err.Error()}
 		}
 
-		// Rebase the branch
-		if err := RebaseBranch(targetBranch.Name, m.config.BaseBranch); err != nil {
+		// A stacked branch can't be rebased onto a parent that failed
+		if m.failedNames[targetBranch.Parent] {
+			return branchUpdatedMsg{branch: targetBranch.Name, success: false, error: fmt.Sprintf("%v: '%s'", ErrParentFailed, targetBranch.Parent)}
+		}
+
+		// Rebase (or merge, fast-forward) and push the branch
+		r, err := StartSync(targetBranch, m.config, m.backup)
+		if r == nil {
 			return branchUpdatedMsg{branch: targetBranch.Name, success: false, error: err.Error()}
 		}
+		return m.afterRebaseStep(r, err)
+	}
+}
 
-		// Push the branch
-		if err := PushBranch(targetBranch.Name); err != nil {
-			return branchUpdatedMsg{branch: targetBranch.Name, success: false, error: "push failed"}
+// afterRebaseStep turns the result of a rebase step into the next message: a
+// paused conflict (when pausing is enabled) or the branch's final outcome
+func (m Model) afterRebaseStep(r *Rebase, err error) tea.Msg {
+	conflict := errors.Is(err, ErrRebaseConflict) || errors.Is(err, ErrMergeConflict)
+	if conflict && m.pauseOnConflict() && r.InProgress() {
+		info, infoErr := r.Conflict()
+		if infoErr == nil {
+			return conflictMsg{rebase: r, info: info}
 		}
+	}
+	if err != nil {
+		r.Abort()
+		return branchUpdatedMsg{branch: r.Branch, success: false, error: err.Error()}
+	}
+	if m.isStacked(r.Branch) {
+		if err := r.Finish(); err != nil {
+			return branchUpdatedMsg{branch: r.Branch, success: false, error: err.Error()}
+		}
+		return branchUpdatedMsg{branch: r.Branch, success: true, stacked: true}
+	}
+	if err := FinishSync(r, m.config); err != nil {
+		return branchUpdatedMsg{branch: r.Branch, success: false, error: err.Error()}
+	}
+	return branchUpdatedMsg{branch: r.Branch, success: true}
+}
+
+// isStacked reports whether a branch belongs to a stack
+func (m Model) isStacked(name string) bool {
+	for _, b := range m.branches {
+		if b.Name == name {
+			return b.Stack != ""
+		}
+	}
+	return false
+}
 
-		return branchUpdatedMsg{branch: targetBranch.Name, success: true}
+// pushStack pushes the rebased branches of a stack together
+func (m Model) pushStack() tea.Cmd {
+	names := m.stacked
+	config := m.config
+	return func() (msg tea.Msg) {
+		defer recoverAsError(&msg)
+		return stackPushedMsg{branches: names, err: PushStack(names, config)}
 	}
 }
 
+// pauseOnConflict reports whether rebase conflicts should pause for resolution
+func (m Model) pauseOnConflict() bool {
+	return m.config.PauseOnConflict
+}
+
 // deleteNextBranch deletes the next selected branch
 func (m Model) deleteNextBranch() tea.Cmd {
-	return func() tea.Msg {
+	return func() (msg tea.Msg) {
+		defer recoverAsError(&msg)
+
 		// Find next selected branch for deletion
 		var targetBranch *Branch
 		currentIndex := 0
@@ -988,20 +2568,21 @@ func (m Model) deleteNextBranch() tea.Cmd {
 			return branchDeletedMsg{success: false, error: "branch not found"}
 		}
 
-		// Delete local branch
-		if err := DeleteLocalBranch(targetBranch.Name); err != nil {
-			return branchDeletedMsg{branch: targetBranch.Name, success: false, error: err.Error()}
+		// Delete local and, conditionally, remote branch
+		if m.archive {
+			if err := ArchiveBranch(targetBranch.Name, m.deleteRemote, m.config, m.backup); err != nil {
+				return branchDeletedMsg{branch: targetBranch.Name, success: false, error: err.Error()}
+			}
+			return branchDeletedMsg{branch: targetBranch.Name, success: true}
 		}
 
-		// Conditionally delete remote branch
-		if m.deleteRemote {
-			if err := DeleteRemoteBranch(targetBranch.Name); err != nil {
-				// Special error for partial success
-				return branchDeletedMsg{branch: targetBranch.Name, success: false, error: "local deleted, but remote failed: " + err.Error()}
-			}
+		sha, _ := gitOutput("rev-parse", "--verify", "refs/heads/"+targetBranch.Name)
+		force := m.forceDeletes(targetBranch)
+		if err := DeleteBranch(targetBranch.Name, m.deleteRemote, force, m.config, m.backup); err != nil {
+			return branchDeletedMsg{branch: targetBranch.Name, success: false, error: err.Error()}
 		}
 
-		return branchDeletedMsg{branch: targetBranch.Name, success: true}
+		return branchDeletedMsg{branch: targetBranch.Name, sha: sha, success: true}
 	}
 }
 
@@ -1018,6 +2599,8 @@ func (m Model) View() string {
 		return m.viewConfirmingDelete()
 	case stateConfirmingDeleteType:
 		return m.viewConfirmingDeleteType()
+	case stateConfirmingForce:
+		return m.viewConfirmingForce()
 	case stateConfirmingStash:
 		return m.viewConfirmingStash()
 	case stateUpdating, stateDeleting:
@@ -1032,8 +2615,28 @@ func (m Model) View() string {
 		return m.viewDone()
 	case stateError:
 		return m.viewError()
+	case stateConflict:
+		return m.viewConflict()
+	case stateUndo:
+		return m.viewUndo()
+	case stateCleanup:
+		return m.viewCleanup()
+	case stateLog:
+		return m.viewLog()
+	case stateRemoteBranches:
+		return m.viewRemoteBranches()
+	case stateConfirmingRemoteDelete:
+		return m.viewConfirmingRemoteDelete()
+	case stateArchived:
+		return m.viewArchived()
+	case stateTrash:
+		return m.viewTrash()
+	case statePlan:
+		return m.viewPlan()
 	case stateTagging:
 		return m.viewTagging()
+	case stateEditingBase:
+		return m.viewEditingBase()
 	case stateHelp:
 		return m.viewHelp()
 	}
@@ -1066,6 +2669,9 @@ func (m Model) viewBrowsing() string {
 	remoteInfo := dimStyle.Render("  |  Remote: ") + titleStyle.Render(m.config.UpstreamRemote)
 	currentInfo := dimStyle.Render("  |  Current: ") + titleStyle.Render(m.currentBranch)
 	s.WriteString(lipgloss.JoinHorizontal(lipgloss.Left, baseInfo, remoteInfo, currentInfo))
+	if m.dryRun {
+		s.WriteString(warningStyle.Render("  |  DRY RUN"))
+	}
 	s.WriteString("\n\n")
 
 	// Search bar
@@ -1079,20 +2685,28 @@ func (m Model) viewBrowsing() string {
 		s.WriteString(dimStyle.Render("(esc to clear, / to edit)"))
 		s.WriteString("\n\n")
 	}
+	if m.statusFilter != "" {
+		s.WriteString(infoStyle.Render(fmt.Sprintf("  Status: %s ", m.statusFilter)))
+		s.WriteString(dimStyle.Render("(f for the next status, esc to clear)"))
+		s.WriteString("\n\n")
+	}
 
 	// Get filtered branches
 	filteredBranches := m.getFilteredBranches()
 
-	// Branch list
+	// Branch list, next to the details panel of the branch under the cursor
+	var list strings.Builder
 	if len(m.branches) == 0 {
-		s.WriteString(warningStyle.Render("  No branches found (excluding base branch)"))
+		list.WriteString(warningStyle.Render("  No branches found (excluding base branch)"))
+	} else if len(filteredBranches) == 0 && m.searchQuery == "" {
+		list.WriteString(warningStyle.Render(fmt.Sprintf("  No branches are %s", m.statusFilter)))
 	} else if len(filteredBranches) == 0 {
-		s.WriteString(warningStyle.Render(fmt.Sprintf("  No branches match '%s'", m.searchQuery)))
+		list.WriteString(warningStyle.Render(fmt.Sprintf("  No branches match '%s'", m.searchQuery)))
 	} else {
 		// Show count if filtered
-		if m.searchQuery != "" {
-			s.WriteString(dimStyle.Render(fmt.Sprintf("  Showing %d of %d branches", len(filteredBranches), len(m.branches))))
-			s.WriteString("\n\n")
+		if m.filtering() {
+			list.WriteString(dimStyle.Render(fmt.Sprintf("  Showing %d of %d branches", len(filteredBranches), len(m.branches))))
+			list.WriteString("\n\n")
 		}
 
 		for i, branch := range filteredBranches {
@@ -1140,12 +2754,36 @@ func (m Model) viewBrowsing() string {
 				name = normalStyle.Render(name)
 			}
 
+			// Stacked branches are drawn as a tree below their parent
+			if branch.Depth > 0 && !m.filtering() {
+				name = dimStyle.Render(strings.Repeat("   ", branch.Depth-1)+"└─ ") + name
+			}
+
 			// Behind/ahead info
 			behindAhead := ""
 			if branch.Behind > 0 || branch.Ahead > 0 {
 				behindAhead = dimStyle.Render(fmt.Sprintf(" ↓%d ↑%d", branch.Behind, branch.Ahead))
 			}
 
+			// Unpushed/remote-only commits against the push remote
+			switch badge := originBadge(branch); branch.OriginStatus {
+			case OriginUnpushed:
+				behindAhead += infoStyle.Render(" " + badge)
+			case OriginAhead, OriginDiverged:
+				behindAhead += warningStyle.Render(" " + badge)
+			case OriginLocalOnly:
+				behindAhead += dimStyle.Render(" " + badge)
+			}
+
+			// Strategy and base branch, unless they're the defaults
+			strategy := ""
+			if branch.Strategy != StrategyRebase {
+				strategy = infoStyle.Render(fmt.Sprintf(" [%s]", branch.Strategy))
+			}
+			if branch.Base != m.config.BaseBranch {
+				strategy += infoStyle.Render(fmt.Sprintf(" [on %s]", branch.Base))
+			}
+
 			// Description
 			desc := ""
 			if branch.Description != "" {
@@ -1158,177 +2796,744 @@ func (m Model) viewBrowsing() string {
 				lastCommit = dimStyle.Render(fmt.Sprintf(" (%s)", branch.LastCommit))
 			}
 
-			line := fmt.Sprintf("%s%s %s %s%s%s%s",
-				cursor, checkbox, status, name, behindAhead, desc, lastCommit)
+			line := fmt.Sprintf("%s%s %s %s%s%s%s%s",
+				cursor, checkbox, status, name, behindAhead, strategy, desc, lastCommit)
 
-			s.WriteString(line)
+			list.WriteString(line)
+			list.WriteString("\n")
+		}
+	}
+
+	if m.showDetails() && m.cursor < len(filteredBranches) {
+		s.WriteString(m.viewDetails(list.String(), filteredBranches[m.cursor]))
+		s.WriteString("\n")
+	} else {
+		s.WriteString(list.String())
+	}
+	s.WriteString("\n")
+
+	// Message
+	if m.message != "" {
+		s.WriteString(warningStyle.Render("  " + m.message))
+		s.WriteString("\n\n")
+	}
+
+	// Help
+	if m.searchMode {
+		s.WriteString(dimStyle.Render("  Type to search  enter/esc: exit search"))
+	} else if m.deleteMode {
+		s.WriteString(lipgloss.JoinHorizontal(lipgloss.Left,
+			dimStyle.Render("  "),
+			errorStyle.Render("space"), dimStyle.Render(": select  "),
+			errorStyle.Render("d"), dimStyle.Render(": confirm delete  "),
+			errorStyle.Render("esc"), dimStyle.Render(": cancel"),
+		))
+	} else {
+		s.WriteString(lipgloss.JoinHorizontal(lipgloss.Left,
+			dimStyle.Render("  "),
+			titleStyle.Render("↑/↓"), dimStyle.Render(": navigate  "),
+			titleStyle.Render("space"), dimStyle.Render(": select  "),
+			titleStyle.Render("a"), dimStyle.Render(": all  "),
+			titleStyle.Render("n"), dimStyle.Render(": none  "),
+			titleStyle.Render("c"), dimStyle.Render(": checkout  "),
+			titleStyle.Render("/"), dimStyle.Render(": search  "),
+			titleStyle.Render("f"), dimStyle.Render(": filter status  "),
+			titleStyle.Render("t"), dimStyle.Render(": tag  "),
+			titleStyle.Render("s"), dimStyle.Render(": strategy  "),
+			titleStyle.Render("b"), dimStyle.Render(": base  "),
+			titleStyle.Render("l"), dimStyle.Render(": log  "),
+			titleStyle.Render("D"), dimStyle.Render(": diff  "),
+			titleStyle.Render("v"), dimStyle.Render(": details  "),
+			titleStyle.Render("h"), dimStyle.Render(": help  "),
+			titleStyle.Render("enter"), dimStyle.Render(": update  "),
+			titleStyle.Render("p"), dimStyle.Render(": dry run  "),
+			titleStyle.Render("d"), dimStyle.Render(": delete mode  "),
+			titleStyle.Render("x"), dimStyle.Render(": cleanup  "),
+			titleStyle.Render("R"), dimStyle.Render(": remote branches  "),
+			titleStyle.Render("A"), dimStyle.Render(": archived  "),
+			titleStyle.Render("T"), dimStyle.Render(": trash  "),
+			titleStyle.Render("u"), dimStyle.Render(": undo  "),
+			titleStyle.Render("q"), dimStyle.Render(": quit"),
+		))
+	}
+
+	return s.String()
+}
+
+func (m Model) viewConfirming() string {
+	var s strings.Builder
+
+	s.WriteString(titleStyle.Render("🌿 GitSync - Confirmation"))
+	s.WriteString("\n\n")
+
+	s.WriteString(boxStyle.Render(m.message))
+	s.WriteString("\n\n")
+
+	s.WriteString(infoStyle.Render("  Selected branches:"))
+	s.WriteString("\n")
+	for _, branch := range m.branches {
+		if branch.Selected {
+			s.WriteString(fmt.Sprintf("    • %s", branch.Name))
+			s.WriteString(infoStyle.Render(fmt.Sprintf(" [%s on %s]", branch.Strategy, branch.Base)))
+			if branch.Description != "" {
+				s.WriteString(dimStyle.Render(fmt.Sprintf(" - %s", branch.Description)))
+			}
+			s.WriteString("\n")
+		}
+	}
+
+	s.WriteString("\n")
+	s.WriteString(infoStyle.Render("  Operations:"))
+	s.WriteString("\n")
+	bases := strings.Join(RunBases(m.config, m.selectedBranches()), ", ")
+	s.WriteString(fmt.Sprintf("    1. Fetch %s from %s\n", bases, m.config.UpstreamRemote))
+	s.WriteString(fmt.Sprintf("    2. Fast-forward local %s\n", bases))
+	s.WriteString("    3. Rebase each branch onto its base (or merge it in, fast-forward, skip, as shown) in a temporary worktree\n")
+	s.WriteString(fmt.Sprintf("    4. Push each branch to %s (force-with-lease only for rebased branches)\n", m.config.OriginRemote))
+
+	s.WriteString("\n")
+	s.WriteString(dimStyle.Render("  y: confirm  n: cancel"))
+
+	return s.String()
+}
+
+func (m Model) viewConfirmingDelete() string {
+	var s strings.Builder
+
+	s.WriteString(errorStyle.Render("🔥 GitSync - Confirm Deletion"))
+	s.WriteString("\n\n")
+
+	s.WriteString(warningStyle.Render("  You are about to delete the following branches both locally and remotely:"))
+	s.WriteString("\n\n")
+
+	for _, branch := range m.branches {
+		if branch.Selected {
+			s.WriteString(fmt.Sprintf("    • %s", branch.Name))
+			if branch.Cleanup != nil {
+				s.WriteString(dimStyle.Render(fmt.Sprintf(" (%s: %s)", branch.Cleanup.Reason, branch.Cleanup.Detail)))
+			}
 			s.WriteString("\n")
 		}
 	}
 
+	s.WriteString("\n")
+	s.WriteString(boxStyle.Render("The branches are backed up first; press 'u' afterwards to undo."))
+	s.WriteString("\n\n")
+
+	s.WriteString(dimStyle.Render("  Are you sure? (y/n)"))
+
+	return s.String()
+}
+
+func (m Model) viewConfirmingDeleteType() string {
+	var s strings.Builder
+
+	s.WriteString(titleStyle.Render("🔥 GitSync - Deletion Type"))
+	s.WriteString("\n\n")
+
+	s.WriteString(infoStyle.Render("  How would you like to remove the selected branches?"))
+	s.WriteString("\n\n")
+
+	s.WriteString(fmt.Sprintf("  %s: Archive locally to %s<name> (default)\n", selectedStyle.Render("1"), archiveRefPrefix))
+	s.WriteString(fmt.Sprintf("  %s: Archive locally AND on remote '%s' (push the archive, delete the branch there)\n", selectedStyle.Render("2"), m.config.OriginRemote))
+	s.WriteString(fmt.Sprintf("  %s: Delete locally only\n", selectedStyle.Render("3")))
+	s.WriteString(fmt.Sprintf("  %s: Delete locally AND on remote '%s'\n", selectedStyle.Render("4"), m.config.OriginRemote))
+
+	s.WriteString("\n")
+	if m.message != "" {
+		s.WriteString(warningStyle.Render("  " + m.message))
+		s.WriteString("\n\n")
+	}
+	s.WriteString(dimStyle.Render("  Archived branches keep their description and can be restored with 'A'."))
+	s.WriteString("\n")
+	s.WriteString(dimStyle.Render("  Press '1'-'4' to proceed (enter archives locally), or 'esc' to cancel."))
+
+	return s.String()
+}
+
+func (m Model) viewConfirmingForce() string {
+	var s strings.Builder
+
+	u := m.unmerged[m.unmergedIndex]
+	s.WriteString(warningStyle.Render(fmt.Sprintf("⚠️ GitSync - Unmerged Branch (%d/%d)", m.unmergedIndex+1, len(m.unmerged))))
+	s.WriteString("\n\n")
+
+	s.WriteString(fmt.Sprintf("  %s is not merged into %s, so 'git branch -d' refuses to delete it.\n\n", selectedStyle.Render(u.Name), u.Into))
+
+	const maxShown = 15
+	if len(u.Lost) == 0 {
+		s.WriteString(successStyle.Render("  All of its commits are on other branches or remotes, so none would be lost."))
+		s.WriteString("\n")
+	} else {
+		s.WriteString(errorStyle.Render(fmt.Sprintf("  Force-deleting it loses %d commit(s) that no other branch or remote has:", len(u.Lost))))
+		s.WriteString("\n\n")
+		for i, commit := range u.Lost {
+			if i == maxShown {
+				s.WriteString(dimStyle.Render(fmt.Sprintf("    ... and %d more", len(u.Lost)-maxShown)))
+				s.WriteString("\n")
+				break
+			}
+			s.WriteString(fmt.Sprintf("    %s\n", commit))
+		}
+	}
+
+	s.WriteString("\n")
+	s.WriteString(boxStyle.Render(fmt.Sprintf("The branch is backed up to %s<run>/heads/%s first; press 'u' afterwards to undo.", backupRefPrefix, u.Name)))
+	s.WriteString("\n\n")
+
+	s.WriteString(lipgloss.JoinHorizontal(lipgloss.Left,
+		dimStyle.Render("  "),
+		errorStyle.Render("y"), dimStyle.Render(": force-delete (-D)  "),
+		errorStyle.Render("a"), dimStyle.Render(": force-delete this and the rest  "),
+		titleStyle.Render("n"), dimStyle.Render(": keep this branch  "),
+		titleStyle.Render("esc"), dimStyle.Render(": cancel"),
+	))
+
+	return s.String()
+}
+
+func (m Model) viewConfirmingStash() string {
+	var s strings.Builder
+
+	s.WriteString(warningStyle.Render("🤔 GitSync - Uncommitted Changes"))
+	s.WriteString("\n\n")
+
+	s.WriteString(boxStyle.Render(m.message))
+	s.WriteString("\n\n")
+
+	s.WriteString(dimStyle.Render("  y: stash and proceed  n: cancel"))
+
+	return s.String()
+}
+
+// --- Checkout Mode Views ---
+
+func (m Model) viewCheckoutList() string {
+	var s strings.Builder
+	s.WriteString(titleStyle.Render("🌿 GitSync - Checkout"))
+	s.WriteString("\n\n")
+
+	// Search bar
+	if m.searchMode {
+		s.WriteString(infoStyle.Render("  Search: "))
+		s.WriteString(selectedStyle.Render(m.checkoutSearchQuery + "█"))
+		s.WriteString(dimStyle.Render(" (esc to exit search)"))
+		s.WriteString("\n\n")
+	}
+
+	filtered := m.getFilteredCheckoutBranches()
+	// realListCount := len(filtered) + 1
+
+	// Item: Create new branch
+	cursor := "  "
+	if m.checkoutCursor == 0 {
+		cursor = "❯ "
+	}
+	s.WriteString(fmt.Sprintf("%s%s\n", cursor, selectedStyle.Render("[ Create a new branch ]")))
+
+	// List of existing branches
+	for i, branchName := range filtered {
+		// The cursor on the list is i+1 because of the create branch option
+		if m.checkoutCursor == i+1 {
+			cursor = "❯ "
+			s.WriteString(fmt.Sprintf("%s%s\n", cursor, selectedStyle.Render(branchName)))
+		} else {
+			cursor = "  "
+			s.WriteString(fmt.Sprintf("%s%s\n", cursor, normalStyle.Render(branchName)))
+		}
+	}
+
+	s.WriteString("\n")
+	s.WriteString(lipgloss.JoinHorizontal(lipgloss.Left,
+		dimStyle.Render("  "),
+		titleStyle.Render("↑/↓"), dimStyle.Render(": navigate  "),
+		titleStyle.Render("enter"), dimStyle.Render(": select  "),
+		titleStyle.Render("/"), dimStyle.Render(": search  "),
+		titleStyle.Render("n"), dimStyle.Render(": new branch  "),
+		titleStyle.Render("q/esc"), dimStyle.Render(": cancel"),
+	))
+
+	return s.String()
+}
+
+func (m Model) viewCheckoutNew() string {
+	var s strings.Builder
+	s.WriteString(titleStyle.Render("🌿 GitSync - New Branch"))
+	s.WriteString("\n\n")
+
+	s.WriteString(infoStyle.Render("  Enter the name for the new branch:"))
+	s.WriteString("\n\n")
+	s.WriteString(fmt.Sprintf("  %s", selectedStyle.Render(m.newBranchNameInput+"█")))
+	s.WriteString("\n\n")
+	s.WriteString(dimStyle.Render("  enter: continue  esc: cancel"))
+
+	return s.String()
+}
+
+func (m Model) viewCheckoutNewFrom() string {
+	var s strings.Builder
+	s.WriteString(titleStyle.Render("🌿 GitSync - New Branch"))
+	s.WriteString("\n\n")
+
+	s.WriteString(infoStyle.Render(fmt.Sprintf("  Create new branch '%s' from base branch:", m.newBranchNameInput)))
+	s.WriteString("\n")
+	s.WriteString(dimStyle.Render(fmt.Sprintf("  (Leave empty to use default: %s)", m.config.BaseBranch)))
+	s.WriteString("\n\n")
+	s.WriteString(fmt.Sprintf("  %s", selectedStyle.Render(m.newBranchFromInput+"█")))
+	s.WriteString("\n\n")
+	s.WriteString(dimStyle.Render("  enter: create and checkout  esc: back"))
+
+	return s.String()
+}
+
+// --- End Checkout Mode Views ---
+
+func (m Model) viewConflict() string {
+	var s strings.Builder
+
+	merge := m.conflict.Strategy == StrategyMerge
+	if merge {
+		s.WriteString(warningStyle.Render("⚔️  GitSync - Merge Conflict"))
+	} else {
+		s.WriteString(warningStyle.Render("⚔️  GitSync - Rebase Conflict"))
+	}
+	s.WriteString("\n\n")
+
+	s.WriteString(infoStyle.Render(fmt.Sprintf("  Branch: %s", m.conflict.Branch)))
+	if merge {
+		s.WriteString(dimStyle.Render(fmt.Sprintf(" (merging %s)", m.conflict.Base)))
+	} else {
+		s.WriteString(dimStyle.Render(fmt.Sprintf(" (onto %s)", m.conflict.Base)))
+	}
 	s.WriteString("\n")
 
-	// Message
-	if m.message != "" {
-		s.WriteString(warningStyle.Render("  " + m.message))
-		s.WriteString("\n\n")
+	info := m.conflictInfo
+	if info.Commit != "" && merge {
+		s.WriteString(fmt.Sprintf("  Merging: %s %s", selectedStyle.Render(info.Commit), info.Subject))
+		s.WriteString("\n")
+	} else if info.Commit != "" {
+		step := ""
+		if info.Total > 0 {
+			step = fmt.Sprintf(" %d/%d", info.Step, info.Total)
+		}
+		s.WriteString(fmt.Sprintf("  Replaying commit%s: %s %s", step, selectedStyle.Render(info.Commit), info.Subject))
+		s.WriteString("\n")
 	}
+	s.WriteString(dimStyle.Render("  Worktree: " + m.conflict.Dir))
+	s.WriteString("\n\n")
 
-	// Help
-	if m.searchMode {
-		s.WriteString(dimStyle.Render("  Type to search  enter/esc: exit search"))
-	} else if m.deleteMode {
-		s.WriteString(lipgloss.JoinHorizontal(lipgloss.Left,
-			dimStyle.Render("  "),
-			errorStyle.Render("space"), dimStyle.Render(": select  "),
-			errorStyle.Render("d"), dimStyle.Render(": confirm delete  "),
-			errorStyle.Render("esc"), dimStyle.Render(": cancel"),
-		))
+	if len(info.Files) > 0 {
+		s.WriteString(errorStyle.Render("  Conflicted files:"))
+		s.WriteString("\n")
+		for _, file := range info.Files {
+			s.WriteString(fmt.Sprintf("    • %s\n", file))
+		}
+	} else if merge {
+		s.WriteString(successStyle.Render("  ✓ No conflicted files left."))
+		s.WriteString(dimStyle.Render(" Press c to commit the merge."))
+		s.WriteString("\n")
 	} else {
-		s.WriteString(lipgloss.JoinHorizontal(lipgloss.Left,
-			dimStyle.Render("  "),
-			titleStyle.Render("↑/↓"), dimStyle.Render(": navigate  "),
-			titleStyle.Render("space"), dimStyle.Render(": select  "),
-			titleStyle.Render("a"), dimStyle.Render(": all  "),
-			titleStyle.Render("n"), dimStyle.Render(": none  "),
-			titleStyle.Render("c"), dimStyle.Render(": checkout  "),
-			titleStyle.Render("/"), dimStyle.Render(": search  "),
-			titleStyle.Render("t"), dimStyle.Render(": tag  "),
-			titleStyle.Render("h"), dimStyle.Render(": help  "),
-			titleStyle.Render("enter"), dimStyle.Render(": update  "),
-			titleStyle.Render("d"), dimStyle.Render(": delete mode  "),
-			titleStyle.Render("q"), dimStyle.Render(": quit"),
-		))
+		s.WriteString(successStyle.Render("  ✓ No conflicted files left."))
+		s.WriteString(dimStyle.Render(" Press c to continue, or s if the commit is now empty."))
+		s.WriteString("\n")
+	}
+
+	if m.conflictNote != "" {
+		s.WriteString("\n")
+		s.WriteString(warningStyle.Render("  " + m.conflictNote))
+		s.WriteString("\n")
+	}
+
+	s.WriteString("\n")
+	skip := titleStyle.Render("s") + dimStyle.Render(": skip commit  ")
+	if merge {
+		skip = ""
 	}
+	s.WriteString(lipgloss.JoinHorizontal(lipgloss.Left,
+		dimStyle.Render("  "),
+		titleStyle.Render("e"), dimStyle.Render(": open in $EDITOR  "),
+		titleStyle.Render("m"), dimStyle.Render(": mergetool  "),
+		titleStyle.Render("c"), dimStyle.Render(": continue  "),
+		skip,
+		titleStyle.Render("a"), dimStyle.Render(": abort branch"),
+	))
 
 	return s.String()
 }
 
-func (m Model) viewConfirming() string {
+func (m Model) viewUpdating() string {
 	var s strings.Builder
 
-	s.WriteString(titleStyle.Render("🌿 GitSync - Confirmation"))
+	title := "🌿 GitSync - Updating"
+	if m.state == stateDeleting && m.archive {
+		title = "🗄️ GitSync - Archiving"
+	} else if m.state == stateDeleting {
+		title = "🔥 GitSync - Deleting"
+	}
+	s.WriteString(titleStyle.Render(title))
 	s.WriteString("\n\n")
 
-	s.WriteString(boxStyle.Render(m.message))
+	totalSelected := 0
+	for _, b := range m.branches {
+		if b.Selected {
+			totalSelected++
+		}
+	}
+
+	progress := fmt.Sprintf("Progress: %d/%d", m.updateIndex+1, totalSelected)
+	s.WriteString(infoStyle.Render("  " + progress))
 	s.WriteString("\n\n")
 
-	s.WriteString(infoStyle.Render("  Selected branches:"))
-	s.WriteString("\n")
+	// Show branch statuses
 	for _, branch := range m.branches {
-		if branch.Selected {
-			s.WriteString(fmt.Sprintf("    • %s", branch.Name))
-			if branch.Description != "" {
-				s.WriteString(dimStyle.Render(fmt.Sprintf(" - %s", branch.Description)))
+		if !branch.Selected {
+			continue
+		}
+
+		icon := dimStyle.Render("○")
+		status := ""
+
+		if slices.Contains(m.skippedBranches, branch.Name) {
+			icon = dimStyle.Render("-")
+			status = dimStyle.Render(" skipped")
+		} else if branch.Status == "updated" && branch.Strategy != StrategyRebase {
+			icon = successStyle.Render("✓")
+			status = successStyle.Render(fmt.Sprintf(" updated (%s)", branch.Strategy.Verb()))
+		} else if branch.Status == "updated" {
+			icon = successStyle.Render("✓")
+			status = successStyle.Render(" updated")
+		} else if branch.Status == "deleted" || branch.Status == "archived" {
+			icon = successStyle.Render("✓")
+			status = successStyle.Render(" " + branch.Status)
+		} else if branch.Status == "rebased" {
+			icon = successStyle.Render("✓")
+			status = dimStyle.Render(fmt.Sprintf(" %s, waiting to push the stack", branch.Strategy.Verb()))
+		} else if plan := m.branchPlan(branch.Name); plan != nil && m.state == stateUpdating {
+			action := "replay"
+			switch plan.Strategy {
+			case StrategyMerge:
+				action = "merge in"
+			case StrategyFFOnly:
+				action = "fast-forward over"
 			}
-			s.WriteString("\n")
+			status = dimStyle.Render(fmt.Sprintf(" %d commit(s) to %s", len(plan.Commits), action))
+			if plan.WouldFail() {
+				status += warningStyle.Render(" (failure predicted)")
+			}
+		}
+
+		name := branch.Name
+		if m.state == stateUpdating && branch.Strategy != StrategyRebase {
+			name += infoStyle.Render(fmt.Sprintf(" [%s]", branch.Strategy))
+		}
+		if m.state == stateUpdating && branch.Base != m.config.BaseBranch {
+			name += infoStyle.Render(fmt.Sprintf(" [on %s]", branch.Base))
 		}
+		s.WriteString(fmt.Sprintf("  %s %s%s\n", icon, name, status))
 	}
 
 	s.WriteString("\n")
-	s.WriteString(infoStyle.Render("  Operations:"))
-	s.WriteString("\n")
-	s.WriteString(fmt.Sprintf("    1. Fetch %s/%s\n", m.config.UpstreamRemote, m.config.BaseBranch))
-	s.WriteString(fmt.Sprintf("    2. Update local %s\n", m.config.BaseBranch))
-	s.WriteString(fmt.Sprintf("    3. Rebase each branch onto %s\n", m.config.BaseBranch))
-	s.WriteString("    4. Push each branch to origin\n")
+	s.WriteString(dimStyle.Render("  Please wait..."))
+	s.WriteString("\n\n")
 
-	s.WriteString("\n")
-	s.WriteString(dimStyle.Render("  y: confirm  n: cancel"))
+	// Display the commands the run executes, unless show_command_log is off
+	if len(m.commandLog) > 0 && m.config.ShowCommandLog {
+		s.WriteString(boxStyle.Render(
+			lipgloss.JoinVertical(lipgloss.Left,
+				infoStyle.Render("Commands that will be ran:"),
+				dimStyle.Render(strings.Join(m.commandLog, "\n")),
+			),
+		))
+		s.WriteString("\n")
+	}
 
 	return s.String()
 }
 
-func (m Model) viewConfirmingDelete() string {
+func (m Model) viewDone() string {
 	var s strings.Builder
 
-	s.WriteString(errorStyle.Render("🔥 GitSync - Confirm Deletion"))
+	s.WriteString(titleStyle.Render("🌿 GitSync - Complete"))
 	s.WriteString("\n\n")
 
-	s.WriteString(warningStyle.Render("  You are about to delete the following branches both locally and remotely:"))
-	s.WriteString("\n\n")
+	if m.deleteMode {
+		if m.successCount > 0 {
+			verb := "deleted"
+			if m.archive {
+				verb = "archived"
+			}
+			s.WriteString(successStyle.Render(fmt.Sprintf("  ✓ Successfully %s %d branch(es)", verb, m.successCount)))
+			s.WriteString("\n\n")
+			for _, branch := range m.branches {
+				if branch.Selected && branch.Status == verb {
+					s.WriteString(fmt.Sprintf("    • %s", branch.Name))
+					if sha := m.deletedSHAs[branch.Name]; sha != "" {
+						s.WriteString(dimStyle.Render(fmt.Sprintf(" (was %s)", shortSHA(sha))))
+					}
+					s.WriteString("\n")
+				}
+			}
+			if !m.archive {
+				s.WriteString("\n")
+				s.WriteString(dimStyle.Render("  Changed your mind? Press T to restore them from the trash."))
+				s.WriteString("\n")
+			}
+		}
+	} else { // stateUpdating
+		if m.successCount > 0 {
+			s.WriteString(successStyle.Render(fmt.Sprintf("  ✓ Successfully updated %d branch(es)", m.successCount)))
+			s.WriteString("\n\n")
 
-	for _, branch := range m.branches {
-		if branch.Selected {
-			s.WriteString(fmt.Sprintf("    • %s\n", branch.Name))
+			for _, branch := range m.branches {
+				if branch.Selected && branch.Status == "updated" {
+					s.WriteString(fmt.Sprintf("    • %s", branch.Name))
+					s.WriteString(dimStyle.Render(fmt.Sprintf(" (%s)", branch.Strategy.Verb())))
+					s.WriteString("\n")
+				}
+			}
+		}
+
+		if len(m.skippedBranches) > 0 {
+			s.WriteString("\n")
+			s.WriteString(dimStyle.Render(fmt.Sprintf("  - Skipped %d branch(es) set to the skip strategy", len(m.skippedBranches))))
+			s.WriteString("\n\n")
+			for _, name := range m.skippedBranches {
+				s.WriteString(dimStyle.Render(fmt.Sprintf("    • %s", name)))
+				s.WriteString("\n")
+			}
 		}
 	}
 
-	s.WriteString("\n")
-	s.WriteString(boxStyle.Render("This action cannot be undone."))
-	s.WriteString("\n\n")
+	if len(m.failedBranches) > 0 {
+		s.WriteString("\n")
+		s.WriteString(errorStyle.Render(fmt.Sprintf("  ✗ Failed: %d branch(es)", len(m.failedBranches))))
+		s.WriteString("\n\n")
 
-	s.WriteString(dimStyle.Render("  Are you sure? (y/n)"))
+		for _, failed := range m.failedBranches {
+			s.WriteString(fmt.Sprintf("    • %s\n", failed))
+		}
+
+		s.WriteString("\n")
+		s.WriteString(warningStyle.Render("  Next steps:"))
+		s.WriteString("\n")
+		if m.deleteMode {
+			s.WriteString("    1. Manually delete (or archive) the failed branches if desired.\n")
+		} else {
+			s.WriteString("    1. Checkout the failed branch\n")
+			s.WriteString("    2. Resolve conflicts manually\n")
+			s.WriteString(fmt.Sprintf("    3. Run: git rebase %s\n", m.config.BaseBranch))
+			s.WriteString(fmt.Sprintf("    4. Push: git push %s <branch> --force-with-lease\n", m.config.OriginRemote))
+		}
+	}
+
+	if m.cancelling && m.updateIndex < m.selectedForActionCount {
+		s.WriteString("\n")
+		s.WriteString(warningStyle.Render(fmt.Sprintf("  Cancelled: %d branch(es) not processed", m.selectedForActionCount-m.updateIndex)))
+		s.WriteString("\n")
+	}
+
+	if status := m.viewRestoreStatus(); status != "" {
+		s.WriteString("\n")
+		s.WriteString(status)
+		s.WriteString("\n")
+	}
+
+	s.WriteString("\n")
+	if m.backup.Recorded() {
+		s.WriteString(dimStyle.Render("  Press space/enter to continue, u to undo, q to quit"))
+	} else {
+		s.WriteString(dimStyle.Render("  Press space/enter to continue, q to quit"))
+	}
 
 	return s.String()
 }
 
-func (m Model) viewConfirmingDeleteType() string {
+func (m Model) viewError() string {
 	var s strings.Builder
 
-	s.WriteString(titleStyle.Render("🔥 GitSync - Deletion Type"))
+	s.WriteString(titleStyle.Render("🌿 GitSync - Error"))
 	s.WriteString("\n\n")
 
-	s.WriteString(infoStyle.Render("  How would you like to delete the selected branches?"))
+	s.WriteString(errorStyle.Render("  ✗ " + m.error))
 	s.WriteString("\n\n")
 
-	s.WriteString(fmt.Sprintf("  %s: Delete locally only\n", selectedStyle.Render("1")))
-	s.WriteString(fmt.Sprintf("  %s: Delete locally AND on remote 'origin'\n", selectedStyle.Render("2")))
+	if status := m.viewRestoreStatus(); status != "" {
+		s.WriteString(status)
+		s.WriteString("\n\n")
+	}
 
-	s.WriteString("\n")
-	s.WriteString(dimStyle.Render("  Press '1' or '2' to proceed, or 'esc' to cancel."))
+	s.WriteString(dimStyle.Render("  Press space/enter to continue, q to quit"))
 
 	return s.String()
 }
 
-func (m Model) viewConfirmingStash() string {
+// viewRestoreStatus reports the outcome of the teardown after a run
+func (m Model) viewRestoreStatus() string {
+	if m.restoreErr != "" {
+		return errorStyle.Render("  ✗ " + m.restoreErr)
+	}
+	if m.restore != nil && m.restore.Stashed {
+		return infoStyle.Render("  ✓ Stashed changes have been restored.")
+	}
+	return ""
+}
+
+func (m Model) viewPlan() string {
 	var s strings.Builder
 
-	s.WriteString(warningStyle.Render("🤔 GitSync - Uncommitted Changes"))
+	s.WriteString(titleStyle.Render("🔮 GitSync - Dry Run"))
+	s.WriteString("\n\n")
+	s.WriteString(dimStyle.Render("  Nothing has been changed. This is what a sync would do:"))
 	s.WriteString("\n\n")
 
-	s.WriteString(boxStyle.Render(m.message))
+	for i, base := range m.plan.AllBases() {
+		upstream := fmt.Sprintf("%s/%s", m.config.UpstreamRemote, base.Name)
+		switch {
+		case base.From == "":
+			s.WriteString(infoStyle.Render(fmt.Sprintf("  %s: no local branch, branches are synced onto %s", base.Name, upstream)))
+			s.WriteString("\n")
+			continue
+		case base.Diverged && i == 0:
+			s.WriteString(errorStyle.Render(fmt.Sprintf("  ✗ %s has diverged from %s, the sync would stop here", base.Name, upstream)))
+		case base.Diverged:
+			s.WriteString(errorStyle.Render(fmt.Sprintf("  ✗ %s has diverged from %s, the branches on it would fail", base.Name, upstream)))
+		case base.Commits == 0:
+			s.WriteString(infoStyle.Render(fmt.Sprintf("  %s is up to date", base.Name)))
+		default:
+			s.WriteString(infoStyle.Render(fmt.Sprintf("  %s: fast-forward %s..%s (%d commit(s))", base.Name, shortSHA(base.From), shortSHA(base.To), base.Commits)))
+		}
+		s.WriteString("\n")
+		s.WriteString(m.viewLeaseCheck(base.Push))
+		s.WriteString("\n")
+	}
+
+	for _, b := range m.plan.Branches {
+		s.WriteString("\n")
+		action := fmt.Sprintf(" replays %d commit(s)", len(b.Commits))
+		switch b.Strategy {
+		case StrategyMerge:
+			action = fmt.Sprintf(" merges %s in (%d commit(s))", b.Onto, len(b.Commits))
+		case StrategyFFOnly:
+			action = fmt.Sprintf(" fast-forwards to %s (%d commit(s))", b.Onto, len(b.Commits))
+		}
+		switch {
+		case b.Strategy == StrategySkip:
+			s.WriteString(dimStyle.Render(fmt.Sprintf("  - %s skipped (%s)", b.Name, b.StrategySource)))
+			s.WriteString("\n")
+			continue
+		case b.Blocked != "":
+			s.WriteString(errorStyle.Render(fmt.Sprintf("  ✗ %s: %s", b.Name, b.Blocked)))
+			s.WriteString("\n")
+			continue
+		case b.UpToDate:
+			s.WriteString(successStyle.Render(fmt.Sprintf("  ✓ %s", b.Name)))
+			s.WriteString(dimStyle.Render(fmt.Sprintf(" already up to date with %s", b.Onto)))
+		case b.Conflict:
+			s.WriteString(errorStyle.Render(fmt.Sprintf("  ✗ %s", b.Name)))
+			s.WriteString(dimStyle.Render(action))
+		default:
+			s.WriteString(selectedStyle.Render(fmt.Sprintf("  • %s", b.Name)))
+			s.WriteString(dimStyle.Render(action))
+		}
+		if b.InPlace {
+			s.WriteString(warningStyle.Render(" (in your checkout)"))
+		}
+		s.WriteString("\n")
+
+		if !b.UpToDate {
+			for _, commit := range b.Commits {
+				s.WriteString(dimStyle.Render("      " + commit))
+				s.WriteString("\n")
+			}
+			switch {
+			case b.Conflict:
+				s.WriteString(errorStyle.Render("    ✗ conflict predicted in " + strings.Join(b.ConflictFiles, ", ")))
+			case b.Unpredictable != "":
+				s.WriteString(warningStyle.Render("    ? " + b.Unpredictable))
+			default:
+				s.WriteString(successStyle.Render("    ✓ no conflicts predicted"))
+			}
+			s.WriteString("\n")
+		}
+		s.WriteString(m.viewLeaseCheck(b.Push))
+		s.WriteString("\n")
+	}
+
+	s.WriteString("\n")
+	if failures := m.plan.Failures(); failures > 0 {
+		s.WriteString(warningStyle.Render(fmt.Sprintf("  %d step(s) predicted to fail", failures)))
+	} else {
+		s.WriteString(successStyle.Render("  ✓ No failures predicted"))
+	}
 	s.WriteString("\n\n")
 
-	s.WriteString(dimStyle.Render("  y: stash and proceed  n: cancel"))
+	s.WriteString(lipgloss.JoinHorizontal(lipgloss.Left,
+		dimStyle.Render("  "),
+		titleStyle.Render("enter"), dimStyle.Render(": run this sync  "),
+		titleStyle.Render("esc"), dimStyle.Render(": back"),
+	))
 
 	return s.String()
 }
 
-// --- Checkout Mode Views ---
+// viewLeaseCheck renders the predicted outcome of a push
+func (m Model) viewLeaseCheck(l LeaseCheck) string {
+	text := "    " + l.Describe()
+	switch {
+	case l.Rejected:
+		return errorStyle.Render(text)
+	case l.Error != "":
+		return warningStyle.Render(text)
+	}
+	return dimStyle.Render(text)
+}
 
-func (m Model) viewCheckoutList() string {
+func (m Model) viewCleanup() string {
 	var s strings.Builder
-	s.WriteString(titleStyle.Render("🌿 GitSync - Checkout"))
-	s.WriteString("\n\n")
-
-	// Search bar
-	if m.searchMode {
-		s.WriteString(infoStyle.Render("  Search: "))
-		s.WriteString(selectedStyle.Render(m.checkoutSearchQuery + "█"))
-		s.WriteString(dimStyle.Render(" (esc to exit search)"))
-		s.WriteString("\n\n")
-	}
 
-	filtered := m.getFilteredCheckoutBranches()
-	// realListCount := len(filtered) + 1
+	s.WriteString(titleStyle.Render("🧹 GitSync - Cleanup"))
+	s.WriteString("\n\n")
 
-	// Item: Create new branch
-	cursor := "  "
-	if m.checkoutCursor == 0 {
-		cursor = "❯ "
+	branches := m.cleanupBranches()
+	switch {
+	case m.cleanupLoading:
+		s.WriteString(infoStyle.Render("  Looking for merged, squash-merged, upstream-gone and stale branches..."))
+		s.WriteString("\n")
+	case len(branches) == 0:
+		s.WriteString(successStyle.Render("  Nothing to clean up: no branch is merged, squash-merged, upstream-gone or stale."))
+		s.WriteString("\n")
 	}
-	s.WriteString(fmt.Sprintf("%s%s\n", cursor, selectedStyle.Render("[ Create a new branch ]")))
 
-	// List of existing branches
-	for i, branchName := range filtered {
-		// The cursor on the list is i+1 because of the create branch option
-		if m.checkoutCursor == i+1 {
+	for i, branch := range branches {
+		cursor := "  "
+		name := normalStyle.Render(branch.Name)
+		if i == m.cleanupCursor {
 			cursor = "❯ "
-			s.WriteString(fmt.Sprintf("%s%s\n", cursor, selectedStyle.Render(branchName)))
-		} else {
-			cursor = "  "
-			s.WriteString(fmt.Sprintf("%s%s\n", cursor, normalStyle.Render(branchName)))
+			name = selectedStyle.Render(branch.Name)
+		}
+		checkbox := dimStyle.Render("[ ]")
+		if branch.Selected {
+			checkbox = errorStyle.Render("[✓]")
+		}
+		reason := warningStyle.Render(branch.Cleanup.Reason)
+		if branch.Cleanup.ForceDelete() {
+			reason = successStyle.Render(branch.Cleanup.Reason)
+		}
+		line := fmt.Sprintf("%s%s %s %s %s", cursor, checkbox, name, reason, dimStyle.Render("- "+branch.Cleanup.Detail))
+		if branch.Description != "" {
+			line += dimStyle.Render(fmt.Sprintf(" [%s]", branch.Description))
+		}
+		s.WriteString(line)
+		s.WriteString("\n")
+	}
+
+	if !m.cleanupLoading && len(branches) > 0 {
+		s.WriteString("\n")
+		s.WriteString(dimStyle.Render("  Selected branches are archived by default. Deleted merged and squash-merged branches use -D, their changes are on the base branch."))
+		s.WriteString("\n")
+		if m.config.StaleDays > 0 {
+			s.WriteString(dimStyle.Render(fmt.Sprintf("  Stale: no commits in %d days (stale_days).", m.config.StaleDays)))
+			s.WriteString("\n")
 		}
 	}
 
@@ -1336,184 +3541,493 @@ func (m Model) viewCheckoutList() string {
 	s.WriteString(lipgloss.JoinHorizontal(lipgloss.Left,
 		dimStyle.Render("  "),
 		titleStyle.Render("↑/↓"), dimStyle.Render(": navigate  "),
-		titleStyle.Render("enter"), dimStyle.Render(": select  "),
-		titleStyle.Render("/"), dimStyle.Render(": search  "),
-		titleStyle.Render("n"), dimStyle.Render(": new branch  "),
-		titleStyle.Render("q/esc"), dimStyle.Render(": cancel"),
+		titleStyle.Render("space"), dimStyle.Render(": select  "),
+		titleStyle.Render("a"), dimStyle.Render(": all  "),
+		titleStyle.Render("n"), dimStyle.Render(": none  "),
+		errorStyle.Render("d"), dimStyle.Render(": archive/delete selected  "),
+		titleStyle.Render("esc"), dimStyle.Render(": back"),
 	))
 
 	return s.String()
 }
 
-func (m Model) viewCheckoutNew() string {
+func (m Model) viewLog() string {
 	var s strings.Builder
-	s.WriteString(titleStyle.Render("🌿 GitSync - New Branch"))
-	s.WriteString("\n\n")
 
-	s.WriteString(infoStyle.Render("  Enter the name for the new branch:"))
-	s.WriteString("\n\n")
-	s.WriteString(fmt.Sprintf("  %s", selectedStyle.Render(m.newBranchNameInput+"█")))
+	b := m.logBranch
+	what := m.config.UpstreamRemote + "/" + b.Base + ".." + b.Name
+	if m.logFull {
+		what = "full history"
+	}
+	title := "📜 GitSync - Log of " + b.Name + " (" + what + ")"
+	if m.logDiff {
+		title = "📜 GitSync - Diff of " + b.Name + " (" + m.config.UpstreamRemote + "/" + b.Base + "..." + b.Name + ")"
+	}
+	s.WriteString(titleStyle.Render(title))
 	s.WriteString("\n\n")
-	s.WriteString(dimStyle.Render("  enter: continue  esc: cancel"))
 
-	return s.String()
-}
+	switch {
+	case m.logLoading:
+		s.WriteString(infoStyle.Render("  Loading the log..."))
+		s.WriteString("\n")
+	case m.logErr != "":
+		s.WriteString(errorStyle.Render("  " + m.logErr))
+		s.WriteString("\n")
+	case len(m.logLines) == 0 && m.logDiff:
+		s.WriteString(dimStyle.Render(fmt.Sprintf("  No changes: %s has nothing that isn't on %s/%s.", b.Name, m.config.UpstreamRemote, b.Base)))
+		s.WriteString("\n")
+	case len(m.logLines) == 0:
+		s.WriteString(dimStyle.Render(fmt.Sprintf("  No commits of its own: %s has nothing that isn't on %s/%s. Press a for the full history.", b.Name, m.config.UpstreamRemote, b.Base)))
+		s.WriteString("\n")
+	}
 
-func (m Model) viewCheckoutNewFrom() string {
-	var s strings.Builder
-	s.WriteString(titleStyle.Render("🌿 GitSync - New Branch"))
-	s.WriteString("\n\n")
+	current := -1
+	if m.logMatch < len(m.logMatches) {
+		current = m.logMatches[m.logMatch]
+	}
+	end := min(m.logOffset+m.logPageSize(), len(m.logLines))
+	for i := m.logOffset; i < end; i++ {
+		line := m.logLines[i]
+		if m.logQuery != "" && slices.Contains(m.logMatches, i) {
+			// Matching lines lose their colors so the matches stand out
+			style := warningStyle
+			if i == current {
+				style = selectedStyle
+			}
+			line = highlightMatches(stripANSI(line), m.logQuery, style)
+		}
+		if m.width > 0 {
+			line = truncate.StringWithTail(line, uint(max(m.width-3, 10)), "…")
+		}
+		s.WriteString("  " + line + "\x1b[0m")
+		s.WriteString("\n")
+	}
 
-	s.WriteString(infoStyle.Render(fmt.Sprintf("  Create new branch '%s' from base branch:", m.newBranchNameInput)))
 	s.WriteString("\n")
-	s.WriteString(dimStyle.Render(fmt.Sprintf("  (Leave empty to use default: %s)", m.config.BaseBranch)))
-	s.WriteString("\n\n")
-	s.WriteString(fmt.Sprintf("  %s", selectedStyle.Render(m.newBranchFromInput+"█")))
+	if len(m.logLines) > 0 {
+		position := fmt.Sprintf("  lines %d-%d of %d", m.logOffset+1, end, len(m.logLines))
+		if m.logFull && !m.logDiff {
+			position += fmt.Sprintf(" (at most %d commits)", logFullLimit)
+		}
+		s.WriteString(dimStyle.Render(position))
+	}
+	switch {
+	case m.logSearching:
+		s.WriteString(fmt.Sprintf("  %s %s", infoStyle.Render("/"), m.logQuery+"█"))
+	case m.logQuery != "" && len(m.logMatches) == 0:
+		s.WriteString(warningStyle.Render(fmt.Sprintf("  no matches for %q", m.logQuery)))
+	case m.logQuery != "":
+		s.WriteString(infoStyle.Render(fmt.Sprintf("  match %d of %d for %q", m.logMatch+1, len(m.logMatches), m.logQuery)))
+	}
 	s.WriteString("\n\n")
-	s.WriteString(dimStyle.Render("  enter: create and checkout  esc: back"))
+
+	s.WriteString(lipgloss.JoinHorizontal(lipgloss.Left,
+		dimStyle.Render("  "),
+		titleStyle.Render("↑/↓"), dimStyle.Render(": scroll  "),
+		titleStyle.Render("space/b"), dimStyle.Render(": page  "),
+		titleStyle.Render("g/G"), dimStyle.Render(": top/bottom  "),
+		titleStyle.Render("a"), dimStyle.Render(": toggle full history  "),
+		titleStyle.Render("d"), dimStyle.Render(": log/diff  "),
+		titleStyle.Render("/"), dimStyle.Render(": search  "),
+		titleStyle.Render("n/N"), dimStyle.Render(": next/previous match  "),
+		titleStyle.Render("esc"), dimStyle.Render(": back"),
+	))
 
 	return s.String()
 }
 
-// --- End Checkout Mode Views ---
-
-func (m Model) viewUpdating() string {
+// highlightMatches renders line with every case-insensitive occurrence of query in style
+func highlightMatches(line string, query string, style lipgloss.Style) string {
 	var s strings.Builder
+	lower, query := strings.ToLower(line), strings.ToLower(query)
+	for {
+		i := strings.Index(lower, query)
+		if i < 0 || query == "" {
+			s.WriteString(line)
+			return s.String()
+		}
+		s.WriteString(line[:i])
+		s.WriteString(style.Reverse(true).Render(line[i : i+len(query)]))
+		line, lower = line[i+len(query):], lower[i+len(query):]
+	}
+}
 
-	title := "🌿 GitSync - Updating"
-	if m.state == stateDeleting {
-		title = "🔥 GitSync - Deleting"
+// detailsMinWidth is the narrowest terminal the details panel is shown in
+const detailsMinWidth = 100
+
+// viewDetails lays out the branch list with the details panel of branch on its right
+func (m Model) viewDetails(list string, branch *Branch) string {
+	panelWidth := max(m.width*2/5, 40)
+	listWidth := m.width - panelWidth - 1
+
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSuffix(list, "\n"), "\n") {
+		lines = append(lines, truncate.StringWithTail(line, uint(listWidth), "…"))
 	}
-	s.WriteString(titleStyle.Render(title))
-	s.WriteString("\n\n")
+	left := lipgloss.NewStyle().Width(listWidth).Render(strings.Join(lines, "\n"))
 
-	totalSelected := 0
-	for _, b := range m.branches {
-		if b.Selected {
-			totalSelected++
+	// Leave room for the header, the help and the panel's border
+	height := 20
+	if m.height > 0 {
+		height = max(m.height-14, 8)
+	}
+	height = max(height, len(lines))
+	textWidth := panelWidth - 4
+
+	var body []string
+	add := func(line string) {
+		body = append(body, truncate.StringWithTail(line, uint(textWidth), "…")+"\x1b[0m")
+	}
+	section := func(title string, lines []string, empty string) {
+		add(infoStyle.Render(title))
+		if len(lines) == 0 {
+			add(dimStyle.Render("  " + empty))
 		}
+		for _, line := range lines {
+			add("  " + line)
+		}
+		add("")
 	}
 
-	progress := fmt.Sprintf("Progress: %d/%d", m.updateIndex+1, totalSelected)
-	s.WriteString(infoStyle.Render("  " + progress))
+	base := m.config.UpstreamRemote + "/" + branch.Base
+	add(selectedStyle.Render(branch.Name) + dimStyle.Render(" vs "+base))
+	add("")
+	d := m.details[branch.Name]
+	switch {
+	case d == nil:
+		add(dimStyle.Render("Loading..."))
+	case d.Error != "":
+		add(errorStyle.Render(d.Error))
+	default:
+		section(fmt.Sprintf("Commits on %s (%d)", branch.Name, branch.Ahead), d.Unique, "none, everything is on "+base)
+		section(fmt.Sprintf("Incoming from %s (%d)", base, branch.Behind), d.Incoming, "none, the branch is up to date")
+		section("Changes", d.Stat, "none")
+	}
+
+	// Keep the end of the panel, with its hint, when the sections don't fit
+	if len(body) > height-1 {
+		body = append(body[:height-2], dimStyle.Render("…"))
+	}
+	body = append(body, dimStyle.Render("D: full diff  l: log  v: hide"))
+
+	panel := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		Padding(0, 1).
+		Width(panelWidth - 2).
+		Render(strings.Join(body, "\n"))
+	return lipgloss.JoinHorizontal(lipgloss.Top, left, " ", panel)
+}
+
+// remoteListHeight is how many remote branches are shown at once
+const remoteListHeight = 20
+
+func (m Model) viewRemoteBranches() string {
+	var s strings.Builder
+
+	s.WriteString(titleStyle.Render("☁️ GitSync - Branches on " + m.remote))
 	s.WriteString("\n\n")
 
-	// Show branch statuses
-	for _, branch := range m.branches {
-		if !branch.Selected {
-			continue
+	switch {
+	case m.remoteLoading && len(m.remoteBranches) == 0:
+		s.WriteString(infoStyle.Render("  Listing the branches on " + m.remote + "..."))
+		s.WriteString("\n")
+	case len(m.remoteBranches) == 0:
+		s.WriteString(dimStyle.Render(fmt.Sprintf("  No branches on %s besides the base branches (as of the last fetch).", m.remote)))
+		s.WriteString("\n")
+	}
+
+	// Show a window of the list around the cursor
+	start := 0
+	if m.remoteCursor >= remoteListHeight {
+		start = m.remoteCursor - remoteListHeight + 1
+	}
+	end := min(start+remoteListHeight, len(m.remoteBranches))
+	width := 0
+	for _, b := range m.remoteBranches[start:end] {
+		width = max(width, min(len(b.Name), 40))
+	}
+
+	if start > 0 {
+		s.WriteString(dimStyle.Render(fmt.Sprintf("  ↑ %d more", start)))
+		s.WriteString("\n")
+	}
+	for i := start; i < end; i++ {
+		b := m.remoteBranches[i]
+		cursor := "  "
+		name := normalStyle.Render(fmt.Sprintf("%-*s", width, b.Name))
+		if i == m.remoteCursor {
+			cursor = "❯ "
+			name = selectedStyle.Render(fmt.Sprintf("%-*s", width, b.Name))
+		}
+		checkbox := dimStyle.Render("[ ]")
+		if m.remoteSelected[b.Name] {
+			checkbox = errorStyle.Render("[✓]")
 		}
 
-		icon := dimStyle.Render("○")
-		status := ""
+		var status string
+		switch {
+		case b.Gone:
+			status = dimStyle.Render("gone    ")
+		case b.Merged:
+			status = successStyle.Render("merged  ")
+		default:
+			status = warningStyle.Render("unmerged")
+		}
+		local := dimStyle.Render("      ")
+		if b.Local {
+			local = infoStyle.Render("local ")
+		}
 
-		if branch.Status == "updated" {
-			icon = successStyle.Render("✓")
-			status = successStyle.Render(" updated")
-		} else if branch.Status == "deleted" {
-			icon = successStyle.Render("✓")
-			status = successStyle.Render(" deleted")
+		line := fmt.Sprintf("%s%s %s %s %s %s", cursor, checkbox, name, status, local,
+			dimStyle.Render(fmt.Sprintf("%s %s", b.Date.Local().Format("2006-01-02"), b.Author)))
+		if b.Moved {
+			line += warningStyle.Render(" (pushed to since the last fetch)")
+		}
+		s.WriteString(line)
+		s.WriteString("\n")
+		if i == m.remoteCursor {
+			s.WriteString(dimStyle.Render(fmt.Sprintf("      %s %s", shortSHA(b.SHA), b.Subject)))
+			s.WriteString("\n")
 		}
+	}
+	if end < len(m.remoteBranches) {
+		s.WriteString(dimStyle.Render(fmt.Sprintf("  ↓ %d more", len(m.remoteBranches)-end)))
+		s.WriteString("\n")
+	}
+
+	if len(m.remoteBranches) > 0 {
+		s.WriteString("\n")
+		s.WriteString(dimStyle.Render(fmt.Sprintf("  %d branch(es), %d selected. merged: contained in its base branch on %s. gone: already deleted on %s, only the remote-tracking ref is left.",
+			len(m.remoteBranches), len(m.selectedRemoteBranches()), m.config.UpstreamRemote, m.remote)))
+		s.WriteString("\n")
+	}
 
-		s.WriteString(fmt.Sprintf("  %s %s%s\n", icon, branch.Name, status))
+	if m.remoteNote != "" {
+		s.WriteString("\n")
+		s.WriteString(warningStyle.Render("  " + m.remoteNote))
+		s.WriteString("\n")
 	}
 
 	s.WriteString("\n")
-	s.WriteString(dimStyle.Render("  Please wait..."))
+	s.WriteString(lipgloss.JoinHorizontal(lipgloss.Left,
+		dimStyle.Render("  "),
+		titleStyle.Render("↑/↓"), dimStyle.Render(": navigate  "),
+		titleStyle.Render("space"), dimStyle.Render(": select  "),
+		titleStyle.Render("a"), dimStyle.Render(": all  "),
+		titleStyle.Render("m"), dimStyle.Render(": merged and gone  "),
+		titleStyle.Render("n"), dimStyle.Render(": none  "),
+		errorStyle.Render("d"), dimStyle.Render(": delete selected  "),
+		titleStyle.Render("esc"), dimStyle.Render(": back"),
+	))
+
+	return s.String()
+}
+
+func (m Model) viewConfirmingRemoteDelete() string {
+	var s strings.Builder
+
+	selected := m.selectedRemoteBranches()
+	s.WriteString(titleStyle.Render("☁️ GitSync - Delete Remote Branches"))
+	s.WriteString("\n\n")
+	s.WriteString(warningStyle.Render(fmt.Sprintf("  Delete %d branch(es) on %s?", len(selected), m.remote)))
 	s.WriteString("\n\n")
 
-	// Display predicted commands
-	if len(m.commandLog) > 0 && (m.state == stateUpdating || m.state == stateDeleting) {
+	var names []string
+	for i, b := range selected {
+		if !b.Gone {
+			names = append(names, b.Name)
+		}
+		if i >= remoteListHeight {
+			continue
+		}
+		line := "  • " + b.Name
+		switch {
+		case b.Gone:
+			line += dimStyle.Render(" (already gone, only the remote-tracking ref is removed)")
+		case !b.Merged:
+			line += warningStyle.Render(" (not merged into " + b.Base + ")")
+		}
+		if !b.Local && !b.Gone {
+			line += dimStyle.Render(" (no local branch)")
+		}
+		s.WriteString(line)
+		s.WriteString("\n")
+	}
+	if len(selected) > remoteListHeight {
+		s.WriteString(dimStyle.Render(fmt.Sprintf("  ... and %d more", len(selected)-remoteListHeight)))
+		s.WriteString("\n")
+	}
+
+	if len(names) > 0 {
+		command := "git push --force-with-lease " + m.remote + " --delete " + strings.Join(names[:min(len(names), 8)], " ")
+		if len(names) > 8 {
+			command += fmt.Sprintf(" ... (%d more)", len(names)-8)
+		}
+		s.WriteString("\n")
 		s.WriteString(boxStyle.Render(
 			lipgloss.JoinVertical(lipgloss.Left,
-				infoStyle.Render("Commands that will be ran:"),
-				dimStyle.Render(strings.Join(m.commandLog, "\n")),
+				infoStyle.Render("Command that will be run:"),
+				dimStyle.Render(command),
 			),
 		))
 		s.WriteString("\n")
 	}
+	s.WriteString(dimStyle.Render("  Branches pushed to since the last fetch are not deleted (--force-with-lease). Every branch is backed up first."))
+	s.WriteString("\n")
+
+	s.WriteString("\n")
+	s.WriteString(lipgloss.JoinHorizontal(lipgloss.Left,
+		dimStyle.Render("  "),
+		errorStyle.Render("y/enter"), dimStyle.Render(": delete  "),
+		titleStyle.Render("n/esc"), dimStyle.Render(": back"),
+	))
 
 	return s.String()
 }
 
-func (m Model) viewDone() string {
+func (m Model) viewArchived() string {
 	var s strings.Builder
 
-	s.WriteString(titleStyle.Render("🌿 GitSync - Complete"))
+	s.WriteString(titleStyle.Render("🗄️ GitSync - Archived Branches"))
 	s.WriteString("\n\n")
 
-	if m.deleteMode {
-		if m.successCount > 0 {
-			s.WriteString(successStyle.Render(fmt.Sprintf("  ✓ Successfully deleted %d branch(es)", m.successCount)))
-			s.WriteString("\n\n")
-			for _, branch := range m.branches {
-				if branch.Selected && branch.Status == "deleted" {
-					s.WriteString(fmt.Sprintf("    • %s\n", branch.Name))
-				}
-			}
+	if len(m.archived) == 0 {
+		s.WriteString(dimStyle.Render("  No archived branches. Archive branches from delete mode ('d') or cleanup ('x')."))
+		s.WriteString("\n")
+	}
+	for i, a := range m.archived {
+		cursor := "  "
+		style := normalStyle
+		if i == m.archivedCursor {
+			cursor = "❯ "
+			style = selectedStyle
 		}
-	} else { // stateUpdating
-		if m.successCount > 0 {
-			s.WriteString(successStyle.Render(fmt.Sprintf("  ✓ Successfully updated %d branch(es)", m.successCount)))
-			s.WriteString("\n\n")
-
-			for _, branch := range m.branches {
-				if branch.Selected && branch.Status == "updated" {
-					s.WriteString(fmt.Sprintf("    • %s\n", branch.Name))
-				}
-			}
+		line := fmt.Sprintf("%s%s %s", cursor, dimStyle.Render(a.Date.Local().Format("2006-01-02")), style.Render(a.Name))
+		if a.Description != "" {
+			line += dimStyle.Render(" - " + a.Description)
+		}
+		if a.Remote != "" {
+			line += infoStyle.Render(" [on " + a.Remote + "]")
+		}
+		s.WriteString(line)
+		s.WriteString("\n")
+		if i == m.archivedCursor {
+			s.WriteString(dimStyle.Render(fmt.Sprintf("      %s %s", shortSHA(a.SHA), a.Subject)))
+			s.WriteString("\n")
 		}
 	}
 
-	if len(m.failedBranches) > 0 {
+	if m.archivedNote != "" {
 		s.WriteString("\n")
-		s.WriteString(errorStyle.Render(fmt.Sprintf("  ✗ Failed: %d branch(es)", len(m.failedBranches))))
-		s.WriteString("\n\n")
+		s.WriteString(warningStyle.Render("  " + m.archivedNote))
+		s.WriteString("\n")
+	}
 
-		for _, failed := range m.failedBranches {
-			s.WriteString(fmt.Sprintf("    • %s\n", failed))
-		}
+	s.WriteString("\n")
+	s.WriteString(lipgloss.JoinHorizontal(lipgloss.Left,
+		dimStyle.Render("  "),
+		titleStyle.Render("↑/↓"), dimStyle.Render(": navigate  "),
+		titleStyle.Render("enter"), dimStyle.Render(": restore  "),
+		titleStyle.Render("esc"), dimStyle.Render(": back"),
+	))
 
+	return s.String()
+}
+
+func (m Model) viewTrash() string {
+	var s strings.Builder
+
+	s.WriteString(titleStyle.Render("🗑️ GitSync - Trash"))
+	s.WriteString("\n\n")
+
+	if len(m.trash) == 0 {
+		s.WriteString(dimStyle.Render("  The trash is empty. Every branch gitsync deletes is recorded here."))
 		s.WriteString("\n")
-		s.WriteString(warningStyle.Render("  Next steps:"))
-		s.WriteString("\n")
-		if m.deleteMode {
-			s.WriteString("    1. Manually delete the failed branches if desired.\n")
-		} else {
-			s.WriteString("    1. Checkout the failed branch\n")
-			s.WriteString("    2. Resolve conflicts manually\n")
-			s.WriteString(fmt.Sprintf("    3. Run: git rebase %s\n", m.config.BaseBranch))
-			s.WriteString("    4. Push: git push origin <branch> --force-with-lease\n")
+	}
+	for i, e := range m.trash {
+		cursor := "  "
+		style := normalStyle
+		if i == m.trashCursor {
+			cursor = "❯ "
+			style = selectedStyle
+		}
+		when := e.DeletedAt.Local().Format("2006-01-02 15:04:05")
+		line := fmt.Sprintf("%s%s %s %s", cursor, dimStyle.Render(when), style.Render(e.Name), dimStyle.Render(shortSHA(e.SHA)))
+		if e.Description != "" {
+			line += dimStyle.Render(" - " + e.Description)
 		}
+		if e.RemoteOnly {
+			line += warningStyle.Render(" [deleted on " + e.Remote + " only]")
+		} else if e.RemoteSHA != "" {
+			line += warningStyle.Render(" [deleted on " + e.Remote + "]")
+		}
+		s.WriteString(line)
+		s.WriteString("\n")
 	}
 
-	if m.didStash {
+	if m.trashNote != "" {
 		s.WriteString("\n")
-		s.WriteString(infoStyle.Render("  ✓ Stashed changes have been restored."))
+		s.WriteString(warningStyle.Render("  " + m.trashNote))
 		s.WriteString("\n")
 	}
 
 	s.WriteString("\n")
-	s.WriteString(dimStyle.Render("  Press space/enter to continue, q to quit"))
+	s.WriteString(lipgloss.JoinHorizontal(lipgloss.Left,
+		dimStyle.Render("  "),
+		titleStyle.Render("↑/↓"), dimStyle.Render(": navigate  "),
+		titleStyle.Render("enter"), dimStyle.Render(": restore (and re-push if deleted on the remote)  "),
+		titleStyle.Render("esc"), dimStyle.Render(": back"),
+	))
 
 	return s.String()
 }
 
-func (m Model) viewError() string {
+func (m Model) viewUndo() string {
 	var s strings.Builder
 
-	s.WriteString(titleStyle.Render("🌿 GitSync - Error"))
+	s.WriteString(titleStyle.Render("⏪ GitSync - Undo"))
 	s.WriteString("\n\n")
 
-	s.WriteString(errorStyle.Render("  ✗ " + m.error))
-	s.WriteString("\n\n")
+	if len(m.undoRuns) == 0 {
+		s.WriteString(dimStyle.Render("  No backups yet. Every sync or delete run records one."))
+		s.WriteString("\n")
+	}
+	for i, run := range m.undoRuns {
+		cursor := "  "
+		style := normalStyle
+		if i == m.undoCursor {
+			cursor = "❯ "
+			style = selectedStyle
+		}
+		when := run.Time.Local().Format("2006-01-02 15:04:05")
+		s.WriteString(fmt.Sprintf("%s%s %s\n", cursor, style.Render(when), dimStyle.Render(run.Describe())))
+		if i == m.undoCursor {
+			for _, ref := range run.Refs {
+				name := ref.Branch
+				if ref.Remote != "" {
+					name = ref.Remote + "/" + ref.Branch
+				}
+				s.WriteString(dimStyle.Render(fmt.Sprintf("      %s → %s", name, shortSHA(ref.SHA))))
+				s.WriteString("\n")
+			}
+		}
+	}
 
-	if m.didStash {
-		s.WriteString(infoStyle.Render("  ✓ Stashed changes have been restored."))
-		s.WriteString("\n\n")
+	if m.undoNote != "" {
+		s.WriteString("\n")
+		s.WriteString(warningStyle.Render("  " + m.undoNote))
+		s.WriteString("\n")
 	}
 
-	s.WriteString(dimStyle.Render("  Press space/enter to continue, q to quit"))
+	s.WriteString("\n")
+	s.WriteString(lipgloss.JoinHorizontal(lipgloss.Left,
+		dimStyle.Render("  "),
+		titleStyle.Render("↑/↓"), dimStyle.Render(": navigate  "),
+		titleStyle.Render("enter"), dimStyle.Render(": restore local branches  "),
+		titleStyle.Render("r"), dimStyle.Render(": restore local and remote  "),
+		titleStyle.Render("esc"), dimStyle.Render(": back"),
+	))
 
 	return s.String()
 }
@@ -1539,6 +4053,26 @@ func (m Model) viewTagging() string {
 	return s.String()
 }
 
+func (m Model) viewEditingBase() string {
+	var s strings.Builder
+
+	s.WriteString(titleStyle.Render("🌿 GitSync - Base Branch"))
+	s.WriteString("\n\n")
+
+	s.WriteString(infoStyle.Render(fmt.Sprintf("  Branch: %s", m.baseBranch)))
+	s.WriteString("\n\n")
+
+	s.WriteString("  Base: ")
+	s.WriteString(selectedStyle.Render(m.baseInput + "█"))
+	s.WriteString("\n\n")
+
+	s.WriteString(dimStyle.Render(fmt.Sprintf("  Leave empty to use the bases patterns or base_branch (%s).", m.config.BaseBranch)))
+	s.WriteString("\n\n")
+	s.WriteString(dimStyle.Render("  enter: save  esc: cancel"))
+
+	return s.String()
+}
+
 func (m Model) viewHelp() string {
 	var s strings.Builder
 
@@ -1558,9 +4092,24 @@ func (m Model) viewHelp() string {
 	s.WriteString("\n")
 	s.WriteString(dimStyle.Render("3. You can select one or more branches to update."))
 	s.WriteString("\n")
-	s.WriteString(dimStyle.Render("4. When you start the update, GitSync will first hard-reset your local base branch to match the upstream version."))
+	s.WriteString(dimStyle.Render("4. When you start the update, GitSync will first fast-forward your local base branch to match the upstream version."))
+	s.WriteString("\n")
+	s.WriteString(dimStyle.Render("5. It then, one-by-one, rebases each selected branch onto the updated base branch in a temporary worktree (your checkout is left untouched) and force-pushes it to its push remote (origin_remote, unless branch.<name>.pushRemote or remote.pushDefault is set)."))
+	s.WriteString("\n\n")
+
+	s.WriteString(infoStyle.Render("Strategies:"))
+	s.WriteString("\n")
+	s.WriteString(dimStyle.Render("Each branch is synced with its strategy, shown next to it unless it's the default rebase:\nrebase, merge (merges the base branch in, pushed without force), ff-only (fast-forward only) or skip.\nPress s to cycle a branch's strategy; it's saved in branch.<name>.gitsyncStrategy and overrides the strategies patterns in .gitsync.yaml."))
+	s.WriteString("\n\n")
+
+	s.WriteString(infoStyle.Render("Base Branches:"))
+	s.WriteString("\n")
+	s.WriteString(dimStyle.Render("A branch can target its own base (e.g. a hotfix on release/1.2), shown as [on <base>]: its counts, status and syncs use it.\nPress b to set it; it's saved in branch.<name>.gitsyncBase and overrides the bases patterns in .gitsync.yaml."))
+	s.WriteString("\n\n")
+
+	s.WriteString(infoStyle.Render("Stacked Branches:"))
 	s.WriteString("\n")
-	s.WriteString(dimStyle.Render("5. It then, one-by-one, rebases each selected branch onto the updated base branch and force-pushes it to your 'origin' remote."))
+	s.WriteString(dimStyle.Render("Branches built on other local branches are shown as a tree (└─) and synced as a whole stack:\neach child is rebased onto its rebased parent, then the stack is pushed with a single atomic push."))
 	s.WriteString("\n\n")
 
 	s.WriteString(infoStyle.Render("A Note on Safety:"))
@@ -1568,6 +4117,16 @@ func (m Model) viewHelp() string {
 	s.WriteString(dimStyle.Render("GitSync uses 'git push --force-with-lease'. This is a safer alternative to 'git push --force'.\nIt will not overwrite the remote branch if someone else has pushed new commits to it in the meantime, thus preventing accidental loss of work."))
 	s.WriteString("\n\n")
 
+	s.WriteString(infoStyle.Render("Conflicts:"))
+	s.WriteString("\n")
+	s.WriteString(dimStyle.Render("Run with --pause-on-conflict (or pause_on_conflict: true) to resolve rebase conflicts as they happen:\nopen $EDITOR (e) or a mergetool (m), then continue (c), skip the commit (s) or abort the branch (a)."))
+	s.WriteString("\n\n")
+
+	s.WriteString(infoStyle.Render("Undo:"))
+	s.WriteString("\n")
+	s.WriteString(dimStyle.Render("Before changing any branch, GitSync saves its old commit under refs/gitsync/backup/<run>/.\nPress u to pick a run and put its branches back, locally (enter) or also on the remote (r)."))
+	s.WriteString("\n\n")
+
 	s.WriteString(infoStyle.Render("Commands:"))
 	s.WriteString("\n")
 	s.WriteString(fmt.Sprintf("  %s: navigate up\n", selectedStyle.Render("↑/k")))
@@ -1576,8 +4135,20 @@ func (m Model) viewHelp() string {
 	s.WriteString(fmt.Sprintf("  %s: select all visible branches\n", selectedStyle.Render("a")))
 	s.WriteString(fmt.Sprintf("  %s: deselect all visible branches\n", selectedStyle.Render("n")))
 	s.WriteString(fmt.Sprintf("  %s: add/edit a description for the selected branch\n", selectedStyle.Render("t")))
+	s.WriteString(fmt.Sprintf("  %s: set the base branch of the selected branch\n", selectedStyle.Render("b")))
+	s.WriteString(fmt.Sprintf("  %s: cycle the sync strategy of the selected branch (rebase, merge, ff-only, skip)\n", selectedStyle.Render("s")))
+	s.WriteString(fmt.Sprintf("  %s: show the log of the selected branch (its own commits, a toggles the full history, / searches)\n", selectedStyle.Render("l")))
+	s.WriteString(fmt.Sprintf("  %s: show the full diff of the selected branch since it forked off its base branch\n", selectedStyle.Render("D")))
+	s.WriteString(fmt.Sprintf("  %s: show/hide the details panel (the branch's commits, incoming commits and diffstat)\n", selectedStyle.Render("v")))
 	s.WriteString(fmt.Sprintf("  %s: search/filter branches\n", selectedStyle.Render("/")))
+	s.WriteString(fmt.Sprintf("  %s: cycle the status filter (behind, unpushed, remote-ahead, diverged-from-origin, local-only, in-sync)\n", selectedStyle.Render("f")))
 	s.WriteString(fmt.Sprintf("  %s: start the update process for selected branches\n", selectedStyle.Render("enter")))
+	s.WriteString(fmt.Sprintf("  %s: toggle dry run: enter previews the sync (commits, predicted conflicts, rejected pushes)\n", selectedStyle.Render("p")))
+	s.WriteString(fmt.Sprintf("  %s: cleanup: preselect merged, squash-merged, upstream-gone and stale branches for deletion\n", selectedStyle.Render("x")))
+	s.WriteString(fmt.Sprintf("  %s: list the branches on your push remote and delete the ones you no longer need\n", selectedStyle.Render("R")))
+	s.WriteString(fmt.Sprintf("  %s: list archived branches and restore them\n", selectedStyle.Render("A")))
+	s.WriteString(fmt.Sprintf("  %s: list deleted branches (the trash) and restore them\n", selectedStyle.Render("T")))
+	s.WriteString(fmt.Sprintf("  %s: undo a previous sync or delete run\n", selectedStyle.Render("u")))
 	s.WriteString(fmt.Sprintf("  %s: show this help window\n", selectedStyle.Render("h")))
 	s.WriteString(fmt.Sprintf("  %s: quit the application\n", selectedStyle.Render("q/ctrl+c")))
 
@@ -1593,6 +4164,9 @@ func (m Model) viewHelp() string {
 	s.WriteString("\n")
 	s.WriteString(fmt.Sprintf("  %s: Number of commits the branch is behind the base branch\n", dimStyle.Render("↓<num>")))
 	s.WriteString(fmt.Sprintf("  %s: Number of commits the branch is ahead of the base branch\n", dimStyle.Render("↑<num>")))
+	s.WriteString(fmt.Sprintf("  %s: Commits not pushed to the branch's push remote yet\n", infoStyle.Render("⇡<num>")))
+	s.WriteString(fmt.Sprintf("  %s: Commits on the push remote the local branch doesn't have (⇣? or ⇣<num>+: pushed since the last fetch)\n", warningStyle.Render("⇣<num>")))
+	s.WriteString(fmt.Sprintf("  %s: Branch was never pushed\n", dimStyle.Render("(not pushed)")))
 
 	s.WriteString("\n\n")
 	s.WriteString(dimStyle.Render("  Press h, q, or esc to return"))
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestLateCleanupResultIgnored(t *testing.T) {
+	found := cleanupFoundMsg{candidates: []CleanupCandidate{{Name: "feat", Reason: CleanupMerged}}}
+	tests := []struct {
+		name string
+		msg  cleanupFoundMsg
+	}{
+		{"candidates", found},
+		{"error", cleanupFoundMsg{err: errors.New("for-each-ref failed")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Model{state: stateCleanup, cleanupLoading: true, branches: []*Branch{{Name: "feat"}, {Name: "main"}}}
+
+			// Leave the cleanup view before the scan finishes
+			updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+			m = updated.(Model)
+			if m.state != stateBrowsing || m.cleanupLoading {
+				t.Fatalf("after esc: state = %v, cleanupLoading = %v", m.state, m.cleanupLoading)
+			}
+
+			updated, _ = m.Update(tt.msg)
+			m = updated.(Model)
+			if m.state != stateBrowsing {
+				t.Errorf("a late result moved the browsing view to state %v", m.state)
+			}
+			for _, b := range m.branches {
+				if b.Selected || b.Cleanup != nil {
+					t.Errorf("a late result marked %s: selected %v, cleanup %+v", b.Name, b.Selected, b.Cleanup)
+				}
+			}
+		})
+	}
+
+	// While the view is still loading, the result is applied
+	m := Model{state: stateCleanup, cleanupLoading: true, branches: []*Branch{{Name: "feat"}, {Name: "main"}}}
+	updated, _ := m.Update(found)
+	m = updated.(Model)
+	if m.cleanupLoading || !m.branches[0].Selected || m.branches[0].Cleanup == nil || m.branches[1].Selected {
+		t.Errorf("cleanup result not applied: loading %v, branches %+v %+v", m.cleanupLoading, m.branches[0], m.branches[1])
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import "strings"
+
+// UnmergedBranch is a branch 'git branch -d' refuses to delete
+type UnmergedBranch struct {
+	Name string
+	Into string   // what git checks the branch against: its upstream, or HEAD
+	Lost []string // commits on no other branch or remote-tracking ref ("sha subject"), newest first
+}
+
+// CheckUnmerged returns the branch if 'git branch -d' would refuse to delete
+// it, or nil if it is merged. Like git, it checks the branch against its
+// upstream if it has one, and HEAD otherwise. Lost lists the commits that only
+// the branch has: they survive a force-delete only in gitsync's backup refs.
+// Branches in deleting are being deleted in the same run, and so are the
+// remote-tracking branches in deletingRemote (e.g. "origin/feature"), so their
+// commits don't count.
+func CheckUnmerged(branchName string, deleting []string, deletingRemote []string) (*UnmergedBranch, error) {
+	into, err := gitOutput("rev-parse", "--abbrev-ref", "--verify", "-q", branchName+"@{upstream}")
+	if err != nil || into == "" {
+		into = "HEAD"
+	}
+	if runGit("merge-base", "--is-ancestor", "refs/heads/"+branchName, into) == nil {
+		return nil, nil
+	}
+
+	args := []string{"log", "--format=%h %s", "refs/heads/" + branchName, "--not", "--exclude=" + branchName}
+	for _, name := range deleting {
+		args = append(args, "--exclude="+name)
+	}
+	// --exclude applies to the next --branches or --remotes only
+	args = append(args, "--branches")
+	for _, name := range deletingRemote {
+		args = append(args, "--exclude="+name)
+	}
+	output, err := gitOutput(append(args, "--remotes")...)
+	if err != nil {
+		return nil, err
+	}
+	u := &UnmergedBranch{Name: branchName, Into: into}
+	if output != "" {
+		u.Lost = strings.Split(output, "\n")
+	}
+	return u, nil
+}
+
+// remoteCopies returns the remote-tracking branches of the given branches on
+// their push remotes, e.g. "origin/feature", for CheckUnmerged
+func remoteCopies(config *Config, names []string) []string {
+	var copies []string
+	for _, name := range names {
+		copies = append(copies, config.PushRemote(name)+"/"+name)
+	}
+	return copies
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckUnmerged(t *testing.T) {
+	logArgs := "log --format=%h %s refs/heads/feat --not --exclude=feat"
+	tests := []struct {
+		name     string
+		upstream string
+		merged   bool
+		deleting []string
+		remote   []string // push-remote copies deleted too
+		log      string   // the command listing the lost commits
+		want     *UnmergedBranch
+	}{
+		{name: "merged into its upstream", upstream: "origin/feat", merged: true},
+		{
+			name:     "unmerged",
+			upstream: "origin/feat",
+			log:      logArgs + " --branches --remotes",
+			want:     &UnmergedBranch{Name: "feat", Into: "origin/feat", Lost: []string{"abc1234 Second", "def5678 First"}},
+		},
+		{
+			name: "no upstream",
+			log:  logArgs + " --branches --remotes",
+			want: &UnmergedBranch{Name: "feat", Into: "HEAD", Lost: []string{"abc1234 Second", "def5678 First"}},
+		},
+		{
+			name:     "other branches deleted too",
+			deleting: []string{"feat", "feat-2"},
+			log:      logArgs + " --exclude=feat --exclude=feat-2 --branches --remotes",
+			want:     &UnmergedBranch{Name: "feat", Into: "HEAD", Lost: []string{"abc1234 Second", "def5678 First"}},
+		},
+		{
+			name:     "remote copy deleted too",
+			upstream: "origin/feat",
+			deleting: []string{"feat"},
+			remote:   []string{"origin/feat"},
+			log:      logArgs + " --exclude=feat --branches --exclude=origin/feat --remotes",
+			want:     &UnmergedBranch{Name: "feat", Into: "origin/feat", Lost: []string{"abc1234 Second", "def5678 First"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := useFakeRunner(t)
+			if tt.upstream != "" {
+				fake.On("rev-parse --abbrev-ref --verify -q feat@{upstream}", tt.upstream+"\n", nil)
+			} else {
+				fake.On("rev-parse --abbrev-ref --verify -q feat@{upstream}", "", errNoRef)
+			}
+			if !tt.merged {
+				into := tt.upstream
+				if into == "" {
+					into = "HEAD"
+				}
+				fake.On("merge-base --is-ancestor refs/heads/feat "+into, "", errNoRef)
+			}
+			if tt.log != "" {
+				fake.On(tt.log, "abc1234 Second\ndef5678 First\n", nil)
+			}
+
+			got, err := CheckUnmerged("feat", tt.deleting, tt.remote)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckUnmerged() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForceDeleteBacksUpFirst(t *testing.T) {
+	fake := useFakeRunner(t)
+	useTrash(t, fake)
+	backupRef := backupRefPrefix + "run/heads/feat"
+	fake.On("rev-parse -q --verify refs/heads/feat^{commit}", oldSHA+"\n", nil)
+	fake.On("rev-parse -q --verify "+backupRef, "", errNoRef)
+
+	if err := DeleteBranch("feat", false, true, &Config{OriginRemote: "origin"}, &Backup{ID: "run"}); err != nil {
+		t.Fatal(err)
+	}
+	backedUp, deleted := -1, -1
+	for i, call := range fake.Calls {
+		switch call {
+		case "update-ref " + backupRef + " " + oldSHA:
+			backedUp = i
+		case "branch -D feat":
+			deleted = i
+		}
+	}
+	if backedUp < 0 || deleted < backedUp {
+		t.Errorf("want the backup before 'branch -D', calls: %q", fake.Calls)
+	}
+}
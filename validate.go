@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlLine matches the "line N: " prefix of the errors of yaml.v3
+var yamlLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): `)
+
+// decodeFile strictly decodes a config file's mapping into c: unknown keys,
+// at the top level or inside strategies and bases, are errors. Every problem is
+// reported as "path:line: message".
+func (c *Config) decodeFile(mapping *yaml.Node, path string) error {
+	var errs []error
+	for _, problem := range unknownKeys(mapping, reflect.TypeOf(*c), "") {
+		errs = append(errs, fmt.Errorf("%s:%s", path, problem))
+	}
+	if err := mapping.Decode(c); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			for _, problem := range typeErr.Errors {
+				errs = append(errs, fmt.Errorf("%s", yamlError(path, problem)))
+			}
+		} else {
+			errs = append(errs, fmt.Errorf("%s", yamlError(path, err.Error())))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// yamlError rewrites an error of yaml.v3 as "path:line: message", without the
+// package prefix of Go type names (e.g. "into main.Strategy")
+func yamlError(path string, message string) string {
+	message = strings.TrimPrefix(message, "yaml: ")
+	message = strings.ReplaceAll(message, " into main.", " into ")
+	if m := yamlLine.FindStringSubmatch(message); m != nil {
+		return path + ":" + m[1] + ": " + strings.TrimPrefix(message, m[0])
+	}
+	return path + ": " + message
+}
+
+// unknownKeys returns "line: message" for every key of node that t has no
+// field for, recursing into nested mappings and lists. in is the setting node
+// belongs to, or "" for the top level.
+func unknownKeys(node *yaml.Node, t reflect.Type, in string) []string {
+	var problems []string
+	switch {
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for _, item := range node.Content {
+			problems = append(problems, unknownKeys(item, t.Elem(), in)...)
+		}
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		keys := yamlKeys(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			field, ok := yamlField(t, key.Value)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%d: %s", key.Line, unknownKey(key.Value, in, keys)))
+				continue
+			}
+			if in == "" {
+				problems = append(problems, unknownKeys(node.Content[i+1], field.Type, key.Value)...)
+			} else {
+				problems = append(problems, unknownKeys(node.Content[i+1], field.Type, in)...)
+			}
+		}
+	}
+	return problems
+}
+
+// unknownKey describes an unknown key, suggesting the closest valid one
+func unknownKey(key string, in string, keys []string) string {
+	message := fmt.Sprintf("unknown setting '%s'", key)
+	if in != "" {
+		message = fmt.Sprintf("unknown key '%s' in %s (use %s)", key, in, strings.Join(keys, ", "))
+	}
+	best, bestDistance := "", 3
+	for _, k := range keys {
+		if d := editDistance(key, k); d < bestDistance {
+			best, bestDistance = k, d
+		}
+	}
+	if best != "" {
+		message += fmt.Sprintf(", did you mean '%s'?", best)
+	}
+	return message
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// yamlKeys lists the yaml keys of a struct type, in field order
+func yamlKeys(t reflect.Type) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		if tag, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ","); tag != "" && tag != "-" {
+			keys = append(keys, tag)
+		}
+	}
+	return keys
+}
+
+// yamlField returns the field of a struct type with the given yaml key
+func yamlField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if tag, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ","); tag == key && tag != "" && tag != "-" {
+			return t.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// Validate checks the settings for values gitsync can't use, and that the
+// remotes and base branches they name exist. Remote branches are looked up in
+// the remote-tracking refs, so nothing is fetched. Every problem is returned,
+// each naming the layer the setting came from.
+func (c *Config) Validate() []error {
+	var errs []error
+	problem := func(key string, format string, a ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s (from %s)", key, fmt.Sprintf(format, a...), c.Origin(key)))
+	}
+
+	if _, err := c.BranchFilter(); err != nil {
+		key, message, _ := strings.Cut(err.Error(), ": ")
+		problem(key, "%s", message)
+	}
+	for _, rule := range c.Strategies {
+		if _, err := CompilePattern(rule.Pattern); err != nil {
+			problem("strategies", "%v", err)
+		}
+		if rule.Strategy == "" {
+			problem("strategies", "%q has no strategy", rule.Pattern)
+		} else if _, err := ParseStrategy(string(rule.Strategy)); err != nil {
+			problem("strategies", "%q: %v", rule.Pattern, err)
+		}
+	}
+	for _, rule := range c.Bases {
+		if _, err := CompilePattern(rule.Pattern); err != nil {
+			problem("bases", "%v", err)
+		}
+		if rule.Base == "" {
+			problem("bases", "%q has no base", rule.Pattern)
+		}
+	}
+	if c.StaleDays < 0 {
+		problem("stale_days", "must be 0 (disabled) or more, not %d", c.StaleDays)
+	}
+	if _, err := LogOptions(c.LogFormat); err != nil {
+		problem("log_format", "%s", strings.TrimPrefix(err.Error(), "log_format "))
+	}
+
+	remotes := map[string]bool{}
+	if output, err := gitOutput("remote"); err == nil {
+		for _, name := range strings.Split(output, "\n") {
+			remotes[name] = true
+		}
+	}
+	if !remotes[c.UpstreamRemote] {
+		problem("upstream_remote", "no remote named '%s'", c.UpstreamRemote)
+	}
+	if !remotes[c.OriginRemote] && c.Origin("origin_remote") != originDefault {
+		problem("origin_remote", "no remote named '%s'", c.OriginRemote)
+	}
+
+	if remotes[c.UpstreamRemote] {
+		if c.BaseBranch == "" {
+			problem("base_branch", "not set and could not be auto-detected")
+		} else if !c.remoteBranchExists(c.BaseBranch) {
+			problem("base_branch", "'%s' not found on '%s' (run 'git fetch %s' if it's new)", c.BaseBranch, c.UpstreamRemote, c.UpstreamRemote)
+		}
+		for _, rule := range c.Bases {
+			if rule.Base != "" && !c.remoteBranchExists(rule.Base) {
+				problem("bases", "%q: base '%s' not found on '%s' (run 'git fetch %s' if it's new)", rule.Pattern, rule.Base, c.UpstreamRemote, c.UpstreamRemote)
+			}
+		}
+	}
+	return errs
+}
+
+// remoteBranchExists reports whether the upstream remote has a branch, as of its remote-tracking ref
+func (c *Config) remoteBranchExists(branch string) bool {
+	return runGit("rev-parse", "-q", "--verify", "refs/remotes/"+c.UpstreamRemote+"/"+branch) == nil
+}
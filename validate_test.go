@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestUnknownKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"valid", "base_branch: main\nstrategies:\n  - pattern: a\n    strategy: merge\n", nil},
+		{"typo", "base_branch: main\nstale_dayz: 3\n", []string{"2: unknown setting 'stale_dayz', did you mean 'stale_days'?"}},
+		{"no suggestion", "colour: blue\n", []string{"1: unknown setting 'colour'"}},
+		{
+			"nested",
+			"strategies:\n  - pattern: a\n    stratgy: merge\nbases:\n  - pattern: b\n    branch: dev\n",
+			[]string{
+				"3: unknown key 'stratgy' in strategies (use pattern, strategy), did you mean 'strategy'?",
+				"6: unknown key 'branch' in bases (use pattern, base)",
+			},
+		},
+		// Type mismatches are left to the decoder
+		{"wrong shape", "strategies: merge\nexclude_patterns: {a: b}\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.content), &doc); err != nil {
+				t.Fatal(err)
+			}
+			got := unknownKeys(doc.Content[0], reflect.TypeOf(Config{}), "")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unknownKeys =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYamlError(t *testing.T) {
+	tests := []struct {
+		path    string
+		message string
+		want    string
+	}{
+		{".gitsync.yaml", "yaml: line 3: did not find expected key", ".gitsync.yaml:3: did not find expected key"},
+		{".gitsync.yaml", "line 2: cannot unmarshal !!seq into main.Strategy", ".gitsync.yaml:2: cannot unmarshal !!seq into Strategy"},
+		{".gitsync.yaml", "yaml: control characters are not allowed", ".gitsync.yaml: control characters are not allowed"},
+		// The path is kept as it is, even if it contains "main."
+		{"/tmp/domain.cfg/c.yaml", "line 1: cannot unmarshal !!str `x` into int", "/tmp/domain.cfg/c.yaml:1: cannot unmarshal !!str `x` into int"},
+		{"/srv/main.d/c.yaml", "yaml: did not find expected node content", "/srv/main.d/c.yaml: did not find expected node content"},
+	}
+	for _, tt := range tests {
+		if got := yamlError(tt.path, tt.message); got != tt.want {
+			t.Errorf("yamlError(%q, %q) = %q, want %q", tt.path, tt.message, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GetWorktreeBranches returns the branches checked out in any worktree, mapped to the worktree path
+func GetWorktreeBranches() (map[string]string, error) {
+	output, err := gitOutput("worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+
+	branches := map[string]string{}
+	path := ""
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "worktree ") {
+			path = strings.TrimPrefix(line, "worktree ")
+		} else if strings.HasPrefix(line, "branch refs/heads/") {
+			branches[strings.TrimPrefix(line, "branch refs/heads/")] = path
+		}
+	}
+	return branches, nil
+}
+
+// GetWorktreeRoot returns the top-level directory of the current worktree
+func GetWorktreeRoot() (string, error) {
+	return gitOutput("rev-parse", "--show-toplevel")
+}
+
+// GetMainWorktreeRoot returns the top-level directory of the main worktree,
+// the one the linked worktrees were added from
+func GetMainWorktreeRoot() (string, error) {
+	output, err := gitOutput("worktree", "list", "--porcelain")
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(output, "\n")
+	if !strings.HasPrefix(lines[0], "worktree ") || len(lines) > 1 && lines[1] == "bare" {
+		return "", fmt.Errorf("the repository has no main worktree")
+	}
+	return strings.TrimPrefix(lines[0], "worktree "), nil
+}
+
+// NeedsInPlaceRebase reports whether a branch is checked out in the current worktree,
+// and therefore has to be rebased in place rather than in a temporary worktree
+func NeedsInPlaceRebase(branchName string) bool {
+	current, err := GetCurrentBranch()
+	return err == nil && current == branchName
+}
+
+// addTempWorktree creates a detached worktree at the given commit and returns
+// its path and a function that removes it
+func addTempWorktree(commit string) (string, func(), error) {
+	parent, err := os.MkdirTemp("", "gitsync-")
+	if err != nil {
+		return "", nil, err
+	}
+	dir := filepath.Join(parent, "worktree")
+
+	if err := gitCombined("worktree", "add", "--detach", dir, commit); err != nil {
+		os.RemoveAll(parent)
+		return "", nil, err
+	}
+
+	cleanup := func() {
+		runGit("worktree", "remove", "--force", dir)
+		os.RemoveAll(parent)
+		runGit("worktree", "prune")
+	}
+	return dir, cleanup, nil
+}
+
+// worktreeFor returns the path of the worktree a branch is checked out in, if any
+func worktreeFor(branchName string) (string, bool) {
+	branches, err := GetWorktreeBranches()
+	if err != nil {
+		return "", false
+	}
+	path, ok := branches[branchName]
+	return path, ok
+}
+
+// checkedOutElsewhere reports whether a branch is checked out in a worktree other than the current one
+func checkedOutElsewhere(branchName string) (string, bool) {
+	path, ok := worktreeFor(branchName)
+	if !ok {
+		return "", false
+	}
+	root, err := GetWorktreeRoot()
+	if err == nil && sameDir(root, path) {
+		return "", false
+	}
+	return path, true
+}
+
+// sameDir reports whether two paths point at the same directory
+func sameDir(a string, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return os.SameFile(infoA, infoB)
+}